@@ -1,8 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log/slog"
 	"strconv"
+
+	"github.com/lukehoban/icfp2024/icfp"
 )
 
 /*
@@ -40,6 +44,13 @@ type Op string
 func (Int) isVal() {}
 func (Op) isVal()  {}
 
+// lazyBoard is only printed if the board is logged.
+type lazyBoard [][]Val
+
+func (b lazyBoard) LogValue() slog.Value {
+	return slog.StringValue(PrintBoard(b))
+}
+
 func PrintBoard(board [][]Val) string {
 	s := ""
 	for _, row := range board {
@@ -186,7 +197,7 @@ func Run(a, b int) Val {
 		}
 		m = append(m, r)
 	}
-	fmt.Printf("[t=%d]\n%s\n", t, PrintBoard(m))
+	slog.Debug("board", "t", t, "board", lazyBoard(m))
 	for {
 		newm, ret := Step(m)
 		t++
@@ -194,12 +205,18 @@ func Run(a, b int) Val {
 			return ret
 		}
 		m = newm
-		fmt.Printf("[t=%d]\n%s\n", t, PrintBoard(m))
+		slog.Debug("board", "t", t, "board", lazyBoard(m))
 	}
 }
 
 func do() error {
-	Run(3, 4)
+	logFormat := flag.String("log-format", "", "log format: text or json")
+	logLevel := flag.String("log-level", "", "log level; debug logs the board at each tick")
+	flag.Parse()
+	if err := icfp.SetupLogging(*logFormat, *logLevel); err != nil {
+		return err
+	}
+	fmt.Println(Run(3, 4))
 	return nil
 }
 
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// benchRun holds the mean of each metric of each benchmark in the output of
+// go test -bench, such as benchRun["BenchmarkEval/efficiency4"]["ns/op"].
+type benchRun map[string]map[string]float64
+
+func parseBench(r io.Reader) (benchRun, error) {
+	sums := map[string]map[string]float64{}
+	counts := map[string]map[string]int{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i]
+			}
+		}
+		if sums[name] == nil {
+			sums[name], counts[name] = map[string]float64{}, map[string]int{}
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad value %q for %s", fields[i], name)
+			}
+			sums[name][fields[i+1]] += v
+			counts[name][fields[i+1]]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	run := benchRun{}
+	for name, metrics := range sums {
+		run[name] = map[string]float64{}
+		for unit, sum := range metrics {
+			run[name][unit] = sum / float64(counts[name][unit])
+		}
+	}
+	return run, nil
+}
+
+func readBench(path string) (benchRun, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	run, err := parseBench(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(run) == 0 {
+		return nil, fmt.Errorf("%s: no benchmark results", path)
+	}
+	return run, nil
+}
+
+// compareBench prints how each metric of the benchmarks in both runs
+// changed, marking regressions of more than threshold percent, and returns
+// how many there were. Rates such as MB/s are better higher; every other
+// metric is better lower.
+func compareBench(w io.Writer, before, after benchRun, threshold float64) (int, error) {
+	var names []string
+	for name := range before {
+		if _, ok := after[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tmetric\told\tnew\tdelta\t\t")
+	regressions := 0
+	for _, name := range names {
+		var units []string
+		for unit := range before[name] {
+			if _, ok := after[name][unit]; ok {
+				units = append(units, unit)
+			}
+		}
+		sort.Strings(units)
+		for _, unit := range units {
+			o, n := before[name][unit], after[name][unit]
+			delta, mark := "~", ""
+			if o != 0 {
+				pct := (n - o) / o * 100
+				delta = fmt.Sprintf("%+.1f%%", pct)
+				if strings.HasSuffix(unit, "/s") {
+					pct = -pct
+				}
+				if pct > threshold {
+					mark = "REGRESSION"
+					regressions++
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", strings.TrimPrefix(name, "Benchmark"), unit, formatMetric(o), formatMetric(n), delta, mark)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+	fmt.Fprint(w, onlyIn("old", before, after))
+	fmt.Fprint(w, onlyIn("new", after, before))
+	return regressions, nil
+}
+
+func onlyIn(label string, run, other benchRun) string {
+	var names []string
+	for name := range run {
+		if _, ok := other[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "only in %s: %s\n", label, strings.TrimPrefix(name, "Benchmark"))
+	}
+	return sb.String()
+}
+
+func formatMetric(v float64) string {
+	if v == float64(int64(v)) {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func runBenchcmp(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("benchcmp", flag.ContinueOnError)
+	threshold := fs.Float64("threshold", 10, "percent increase in any metric counted as a regression")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: benchcmp [-threshold percent] old.txt new.txt")
+	}
+	before, err := readBench(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := readBench(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	regressions, err := compareBench(stdout, before, after, *threshold)
+	if err != nil {
+		return err
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%d metrics regressed by more than %g%%", regressions, *threshold)
+	}
+	return nil
+}
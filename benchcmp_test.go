@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const benchOld = `goos: linux
+pkg: github.com/lukehoban/icfp2024/icfp
+BenchmarkEval/efficiency4-8   	     100	  10000000 ns/op	     21932 betas/op	 7000000 B/op	  131536 allocs/op
+BenchmarkEval/efficiency4-8   	     100	  12000000 ns/op	     21932 betas/op	 7000000 B/op	  131536 allocs/op
+BenchmarkEval/operators-8     	    1000	    700000 ns/op	       604.0 betas/op	  380000 B/op	   11225 allocs/op
+BenchmarkEval/gone-8          	    1000	    100 ns/op
+PASS
+`
+
+const benchNew = `BenchmarkEval/efficiency4-8   	     100	  11500000 ns/op	     21932 betas/op	 7000000 B/op	  131536 allocs/op
+BenchmarkEval/operators-8     	    1000	    900000 ns/op	       302.0 betas/op	  380000 B/op	   11225 allocs/op
+`
+
+func TestParseBench(t *testing.T) {
+	run, err := parseBench(strings.NewReader(benchOld))
+	assert.NoError(t, err)
+	assert.Len(t, run, 3)
+	assert.Equal(t, 11000000.0, run["BenchmarkEval/efficiency4"]["ns/op"])
+	assert.Equal(t, 21932.0, run["BenchmarkEval/efficiency4"]["betas/op"])
+	assert.Equal(t, 604.0, run["BenchmarkEval/operators"]["betas/op"])
+}
+
+func TestCompareBench(t *testing.T) {
+	before, _ := parseBench(strings.NewReader(benchOld))
+	after, _ := parseBench(strings.NewReader(benchNew))
+	var buf bytes.Buffer
+	n, err := compareBench(&buf, before, after, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	out := buf.String()
+	assert.Regexp(t, `Eval/efficiency4 +ns/op +11000000 +11500000 +\+4.5%`, out)
+	assert.Regexp(t, `Eval/operators +betas/op +604 +302 +-50.0%`, out)
+	assert.Regexp(t, `Eval/operators +ns/op +700000 +900000 +\+28.6% +REGRESSION`, out)
+	assert.Contains(t, out, "only in old: Eval/gone\n")
+}
+
+func TestCompareBenchRates(t *testing.T) {
+	before, _ := parseBench(strings.NewReader("BenchmarkDecode-8 100 2000 ns/op 50.00 MB/s\n"))
+	after, _ := parseBench(strings.NewReader("BenchmarkDecode-8 100 2500 ns/op 40.00 MB/s\n"))
+	var buf bytes.Buffer
+	n, err := compareBench(&buf, before, after, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Regexp(t, `Decode +MB/s +50 +40 +-20.0% +REGRESSION`, buf.String())
+
+	// Higher throughput is an improvement.
+	buf.Reset()
+	n, err = compareBench(&buf, after, before, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Regexp(t, `Decode +MB/s +40 +50 +\+25.0% +\n`, buf.String())
+}
+
+func TestRunBenchcmp(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.txt"), filepath.Join(dir, "new.txt")
+	os.WriteFile(oldPath, []byte(benchOld), 0644)
+	os.WriteFile(newPath, []byte(benchNew), 0644)
+
+	var buf bytes.Buffer
+	err := runBenchcmp([]string{oldPath, newPath}, nil, &buf)
+	assert.EqualError(t, err, "1 metrics regressed by more than 10%")
+	assert.NoError(t, runBenchcmp([]string{"-threshold", "50", oldPath, newPath}, nil, &buf))
+	// benchcmp is dispatched like every other command.
+	assert.NoError(t, run([]string{"benchcmp", oldPath, oldPath}, nil, &buf))
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lukehoban/icfp2024/icfp"
+)
+
+const usage = `usage: icfp2024 <command> [flags] [args]
+
+Commands:
+  send [text]        send text (or stdin) to the server and print the reply
+  eval [file]        evaluate an ICFP program locally
+  encode [text]      print text as an ICFP string token
+  echo [text]        round-trip text through a string token offline, like the echo service
+  int [n...]         print numbers (decimal or I tokens) in base 94
+  decode [file]      evaluate an ICFP program to text
+  render [file]      print an ICFP program in lambda notation
+  stats [file]       evaluate a program and print its size and evaluation stats
+  profile [file]     evaluate a program and report where its steps go, by lambda
+  minimize [text]    print the shortest program found that evaluates to text
+  queue [list|drain|retry|clean]
+                     show, send, retry or tidy up queued submissions
+  repl               interactive evaluator
+  debug [file]       step through an evaluation
+  serve              run a local stand-in server
+  fetch              download course problems into problems/<course>
+  benchcmp old new   compare two go test -bench outputs for regressions
+
+Programs are read from the file argument, -e, or stdin, as tokens, lambda
+notation or JSON. Run "<command> -h" for the flags of each command.
+`
+
+type options struct {
+	strategy string
+	maxSteps int64
+	maxDepth int
+	timeout  time.Duration
+	maxBytes int64
+	maxMem   int64
+	format   string
+	quiet    bool
+	program  string
+	trace    bool
+	output   string
+	top      int
+
+	logFormat string
+	logLevel  string
+}
+
+func newFlags(name string, o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&o.strategy, "strategy", "need", "application strategy: need, name or value")
+	fs.Int64Var(&o.maxSteps, "max-steps", 0, "step budget (0 for unlimited; send and decode default to icfp.DefaultEvalBudget)")
+	fs.IntVar(&o.maxDepth, "max-depth", 0, "recursion depth budget (0 for unlimited; send and decode default to icfp.DefaultEvalBudget)")
+	fs.DurationVar(&o.timeout, "timeout", 0, "evaluation time budget (0 for unlimited; send and decode default to icfp.DefaultEvalBudget)")
+	fs.Int64Var(&o.maxBytes, "max-bytes", 0, "size budget for each string or integer (0 for unlimited; send and decode default to icfp.DefaultEvalBudget)")
+	fs.Int64Var(&o.maxMem, "max-memory", 0, "estimated bytes of environments and thunks (0 for unlimited; send and decode default to icfp.DefaultEvalBudget)")
+	fs.StringVar(&o.format, "format", "text", "output format: text or json")
+	fs.BoolVar(&o.quiet, "q", false, "print only the result")
+	fs.StringVar(&o.program, "e", "", "program to use instead of a file or stdin")
+	fs.BoolVar(&o.trace, "trace", false, "log each reduction (implies -log-level debug)")
+	fs.StringVar(&o.output, "o", "", "file to write a pprof profile to (profile)")
+	fs.IntVar(&o.top, "top", 20, "lambdas to report, or 0 for all (profile)")
+	fs.StringVar(&o.logFormat, "log-format", "", "log format: text or json (default $ICFP_LOG_FORMAT or text)")
+	fs.StringVar(&o.logLevel, "log-level", "", "log level: debug, info, warn or error (default $ICFP_LOG_LEVEL or info)")
+	return fs
+}
+
+func (o *options) evaluator() (*icfp.Evaluator, error) {
+	strategy, err := icfp.ParseStrategy(o.strategy)
+	if err != nil {
+		return nil, err
+	}
+	ev := &icfp.Evaluator{Strategy: strategy, MaxSteps: o.maxSteps, MaxDepth: o.maxDepth, Timeout: o.timeout, MaxValueBytes: o.maxBytes, MaxMemory: o.maxMem}
+	if o.trace {
+		ev.Tracer = icfp.LogTracer{Logger: slog.Default()}
+	}
+	return ev, nil
+}
+
+func (o *options) budget() icfp.EvalBudget {
+	return icfp.EvalBudget{MaxSteps: o.maxSteps, MaxDepth: o.maxDepth, Timeout: o.timeout, MaxValueBytes: o.maxBytes, MaxMemory: o.maxMem}
+}
+
+// text is the command line arguments joined by spaces, or stdin.
+func (o *options) text(fs *flag.FlagSet, stdin io.Reader) (string, error) {
+	if fs.NArg() > 0 {
+		return strings.Join(fs.Args(), " "), nil
+	}
+	byts, err := io.ReadAll(stdin)
+	return string(byts), err
+}
+
+// source is the program given with -e, in the file argument, or on stdin.
+func (o *options) source(fs *flag.FlagSet, stdin io.Reader) (string, error) {
+	if o.program != "" {
+		return o.program, nil
+	}
+	if fs.NArg() > 1 {
+		return "", fmt.Errorf("expected at most one file, got %d", fs.NArg())
+	}
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		byts, err := os.ReadFile(fs.Arg(0))
+		return string(byts), err
+	}
+	byts, err := io.ReadAll(stdin)
+	return string(byts), err
+}
+
+func (o *options) parse(fs *flag.FlagSet, stdin io.Reader) (icfp.Expr, error) {
+	s, err := o.source(fs, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return newRepl(io.Discard).parse(strings.TrimSpace(s))
+}
+
+// emit prints v as JSON, or text unless it is empty.
+func (o *options) emit(stdout io.Writer, v any, text string) error {
+	if o.format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	if o.format != "text" {
+		return fmt.Errorf("unknown format %q (want text or json)", o.format)
+	}
+	if text != "" {
+		fmt.Fprintln(stdout, text)
+	}
+	return nil
+}
+
+type statsJSON struct {
+	Steps          int64  `json:"steps"`
+	BetaReductions int64  `json:"beta_reductions"`
+	MaxDepth       int    `json:"max_depth"`
+	Time           string `json:"time"`
+}
+
+func newStatsJSON(s icfp.Stats, elapsed time.Duration) *statsJSON {
+	return &statsJSON{Steps: s.Steps, BetaReductions: s.BetaReductions, MaxDepth: s.MaxDepth, Time: elapsed.String()}
+}
+
+func runSend(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	s, err := o.text(fs, stdin)
+	if err != nil {
+		return err
+	}
+	c, err := icfp.DefaultClient()
+	if err != nil {
+		return err
+	}
+	c.Budget = o.budget()
+	resp, err := c.Communicate(context.Background(), s)
+	if err != nil {
+		return err
+	}
+	if o.format == "json" {
+		return o.emit(stdout, map[string]any{"command": s, "raw": resp.Raw, "text": resp.Text, "cached": resp.Cached}, "")
+	}
+	if !o.quiet && resp.Raw != "" {
+		expr, rest := icfp.CombineToExpr(icfp.Parse(resp.Raw))
+		if len(rest) > 0 {
+			fmt.Fprintf(stdout, "WARNING - didn't use all input! %v\n", rest)
+		}
+		for _, d := range icfp.Check(expr) {
+			fmt.Fprintf(stdout, "WARNING - %s\n", d)
+		}
+		fmt.Fprintf(stdout, "%v\n\n", expr)
+		fmt.Fprintf(stdout, "%v\n\n", icfp.RenderAsLambda(expr))
+	}
+	return o.emit(stdout, nil, resp.Text)
+}
+
+func runEval(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	expr, err := o.parse(fs, stdin)
+	if err != nil {
+		return err
+	}
+	ev, err := o.evaluator()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	res, err := ev.Eval(expr, nil)
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	out := map[string]any{"result": icfp.RenderAsLambda(res), "stats": newStatsJSON(ev.Stats, elapsed)}
+	text := icfp.RenderAsLambda(res)
+	if s, ok := res.(icfp.String); ok {
+		out["text"] = string(s)
+		text = string(s)
+	}
+	if !o.quiet {
+		text += fmt.Sprintf("\n[%s: %d steps, %d beta reductions, depth %d, %s]", ev.Strategy, ev.Stats.Steps, ev.Stats.BetaReductions, ev.Stats.MaxDepth, elapsed)
+	}
+	return o.emit(stdout, out, text)
+}
+
+func runEncode(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	s, err := o.text(fs, stdin)
+	if err != nil {
+		return err
+	}
+	if err := icfp.ValidateText(s); err != nil {
+		return textError(err)
+	}
+	tok := string(icfp.StringToToken(s))
+	return o.emit(stdout, map[string]any{"text": s, "token": tok}, tok)
+}
+
+// textError lists every unsupported character of a *icfp.TextError.
+func textError(err error) error {
+	te, ok := err.(*icfp.TextError)
+	if !ok || len(te.Chars) == 1 {
+		return err
+	}
+	var lines []string
+	for _, c := range te.Chars {
+		lines = append(lines, "  "+c.Error())
+	}
+	return fmt.Errorf("%d unsupported characters:\n%s", len(te.Chars), strings.Join(lines, "\n"))
+}
+
+func runEcho(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	s, err := o.text(fs, stdin)
+	if err != nil {
+		return err
+	}
+	tok, echo, err := icfp.LocalEcho(s)
+	if err != nil {
+		return textError(err)
+	}
+	text := echo
+	if !o.quiet {
+		text = tok + "\n" + echo
+	}
+	return o.emit(stdout, map[string]any{"text": s, "token": tok, "echo": echo}, text)
+}
+
+type intJSON struct {
+	Decimal string `json:"decimal"`
+	Token   string `json:"token"`
+	Digits  []int  `json:"digits"`
+}
+
+func runInt(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	s, err := o.text(fs, stdin)
+	if err != nil {
+		return err
+	}
+	var out []intJSON
+	var lines []string
+	for _, arg := range strings.Fields(s) {
+		n, ok := new(big.Int).SetString(arg, 10)
+		if !ok {
+			if !strings.HasPrefix(arg, "I") || len(arg) < 2 || strings.ContainsFunc(arg[1:], func(c rune) bool { return c < '!' || c > '~' }) {
+				return fmt.Errorf("%q is neither a decimal number nor an integer token", arg)
+			}
+			n = icfp.ParseInteger(arg[1:])
+		}
+		digits, tok := icfp.Base94(n)
+		out = append(out, intJSON{Decimal: n.String(), Token: tok, Digits: digits})
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%v", n, tok, digits))
+	}
+	return o.emit(stdout, out, strings.Join(lines, "\n"))
+}
+
+func runDecode(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	s, err := o.source(fs, stdin)
+	if err != nil {
+		return err
+	}
+	resp, err := icfp.DecodeResponse(s, o.budget())
+	if err != nil {
+		return err
+	}
+	return o.emit(stdout, map[string]any{"token": resp.Raw, "text": resp.Text}, resp.Text)
+}
+
+func runRender(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	expr, err := o.parse(fs, stdin)
+	if err != nil {
+		return err
+	}
+	lambda := icfp.RenderAsLambda(expr)
+	return o.emit(stdout, map[string]any{"lambda": lambda}, lambda)
+}
+
+func runStats(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	expr, err := o.parse(fs, stdin)
+	if err != nil {
+		return err
+	}
+	ev, err := o.evaluator()
+	if err != nil {
+		return err
+	}
+	encoded := icfp.Encode(expr)
+	start := time.Now()
+	_, err = ev.Eval(expr, nil)
+	elapsed := time.Since(start)
+	out := map[string]any{
+		"tokens": len(strings.Fields(encoded)),
+		"bytes":  len(encoded),
+		"stats":  newStatsJSON(ev.Stats, elapsed),
+	}
+	text := fmt.Sprintf("tokens: %d\nbytes: %d\nsteps: %d\nbeta reductions: %d\nmax depth: %d\ntime: %s",
+		len(strings.Fields(encoded)), len(encoded), ev.Stats.Steps, ev.Stats.BetaReductions, ev.Stats.MaxDepth, elapsed)
+	if err != nil {
+		out["error"] = err.Error()
+		text += "\nerror: " + err.Error()
+	}
+	return o.emit(stdout, out, text)
+}
+
+// runProfile evaluates a program with a Profile, printing the lambdas that
+// take the most steps and with -o writing a profile for go tool pprof.
+func runProfile(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	expr, err := o.parse(fs, stdin)
+	if err != nil {
+		return err
+	}
+	ev, err := o.evaluator()
+	if err != nil {
+		return err
+	}
+	ev.Profile = icfp.NewProfile()
+	_, evalErr := ev.Eval(expr, nil)
+	if o.output != "" {
+		f, err := os.Create(o.output)
+		if err != nil {
+			return err
+		}
+		if err := ev.Profile.WritePprof(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	if o.format == "json" {
+		return o.emit(stdout, ev.Profile.Funcs(), "")
+	}
+	if err := ev.Profile.WriteReport(stdout, o.top); err != nil {
+		return err
+	}
+	// A program that runs out of budget still has a useful profile.
+	if evalErr != nil {
+		fmt.Fprintf(stdout, "error: %v\n", evalErr)
+	}
+	return nil
+}
+
+// runMinimize finds a short program for text, or with -e for the text a
+// program evaluates to, keeping the program itself as a candidate.
+func runMinimize(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	var s string
+	var candidates []string
+	if o.program != "" {
+		expr, err := newRepl(io.Discard).parse(o.program)
+		if err != nil {
+			return err
+		}
+		ev, err := o.evaluator()
+		if err != nil {
+			return err
+		}
+		res, err := ev.Eval(expr, nil)
+		if err != nil {
+			return err
+		}
+		str, ok := res.(icfp.String)
+		if !ok {
+			return fmt.Errorf("program evaluates to %s, not a string", icfp.RenderAsLambda(res))
+		}
+		s = string(str)
+		candidates = append(candidates, icfp.Encode(expr))
+	} else {
+		var err error
+		if s, err = o.text(fs, stdin); err != nil {
+			return err
+		}
+	}
+	if err := icfp.ValidateText(s); err != nil {
+		return textError(err)
+	}
+	program := icfp.Minimize(context.Background(), s, candidates...)
+	return o.emit(stdout, map[string]any{"text": s, "program": program, "bytes": len(program), "plain_bytes": len(icfp.StringToToken(s))}, program)
+}
+
+func runQueue(o *options, fs *flag.FlagSet, stdin io.Reader, stdout io.Writer) error {
+	c, err := icfp.DefaultClient()
+	if err != nil {
+		return err
+	}
+	if c.Queue == nil {
+		return fmt.Errorf("the submission queue is off")
+	}
+	switch action := fs.Arg(0); action {
+	case "", "list":
+		items := c.Queue.Items()
+		var lines []string
+		for _, item := range items {
+			line := fmt.Sprintf("%d\t%s\t%s\t%d attempts\t%s", item.ID, item.Problem, item.State, item.Attempts, item.Updated.Format(time.RFC3339))
+			if item.Error != "" {
+				line += "\t" + item.Error
+			}
+			lines = append(lines, line)
+		}
+		return o.emit(stdout, items, strings.Join(lines, "\n"))
+	case "drain":
+		n, err := c.Queue.Drain(context.Background(), c)
+		if err != nil {
+			return fmt.Errorf("sent %d submissions, then: %w", n, err)
+		}
+		return o.emit(stdout, map[string]any{"sent": n}, fmt.Sprintf("sent %d submissions", n))
+	case "clean":
+		return c.Queue.Clean()
+	case "retry":
+		n, err := c.Queue.Retry()
+		if err != nil {
+			return err
+		}
+		return o.emit(stdout, map[string]any{"retrying": n}, fmt.Sprintf("%d failed submissions are pending again", n))
+	default:
+		return fmt.Errorf("unknown queue action %q (want list, drain, retry or clean)", action)
+	}
+}
+
+// commands are the subcommands; those taking the shared evaluation flags are
+// wrapped by withOptions.
+var commands = map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+	"send":     withOptions("send", runSend),
+	"eval":     withOptions("eval", runEval),
+	"encode":   withOptions("encode", runEncode),
+	"echo":     withOptions("echo", runEcho),
+	"int":      withOptions("int", runInt),
+	"decode":   withOptions("decode", runDecode),
+	"render":   withOptions("render", runRender),
+	"stats":    withOptions("stats", runStats),
+	"profile":  withOptions("profile", runProfile),
+	"minimize": withOptions("minimize", runMinimize),
+	"queue":    withOptions("queue", runQueue),
+	"repl":     runRepl,
+	"debug":    runDebug,
+	"serve":    runServe,
+	"fetch":    runFetch,
+	"benchcmp": runBenchcmp,
+}
+
+// run runs a command from commands. Anything else is sent as text, which is
+// how the tool worked before it had commands.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	name := "send"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		} else if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			fmt.Fprint(stdout, usage)
+			return nil
+		}
+	}
+	return commands[name](args, stdin, stdout)
+}
+
+// withOptions parses the shared flags for a command and sets up logging
+// before running it.
+func withOptions(name string, f func(*options, *flag.FlagSet, io.Reader, io.Writer) error) func([]string, io.Reader, io.Writer) error {
+	return func(args []string, stdin io.Reader, stdout io.Writer) error {
+		o := &options{}
+		fs := newFlags(name, o)
+		if name == "send" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			// Text without flags is sent as is, even if it contains dashes.
+			args = append([]string{"--"}, args...)
+		}
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if o.trace && o.logLevel == "" {
+			o.logLevel = "debug"
+		}
+		if err := icfp.SetupLogging(o.logFormat, o.logLevel); err != nil {
+			return err
+		}
+		return f(o, fs, stdin, stdout)
+	}
+}
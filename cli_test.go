@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) string {
+	var out bytes.Buffer
+	assert.NoError(t, run(args, strings.NewReader(stdin), &out))
+	return out.String()
+}
+
+func TestCLI(t *testing.T) {
+	assert.Equal(t, "S'%4}).$%8\n", runCLI(t, "", "encode", "get", "index"))
+	assert.Equal(t, "S'%4}).$%8\n", runCLI(t, "get index", "encode"))
+	assert.Equal(t, "get index\n", runCLI(t, "", "decode", "-e", "S'%4}).$%8"))
+	assert.Equal(t, "8\n", runCLI(t, `B$ L# B* v# I# I%`, "eval", "-q"))
+	assert.Equal(t, "get index\n", runCLI(t, `S'%4}).$%8`, "eval", "-q"))
+	assert.Equal(t, "((λz.(* z 2)) 4)\n", runCLI(t, "", "render", "-e", `B$ L# B* v# I# I%`))
+	assert.Contains(t, runCLI(t, "", "eval", "-strategy", "value", "-e", `B$ L# B* v# I# I%`), "[value: 6 steps, 1 beta reductions")
+
+	var stats struct {
+		Tokens int `json:"tokens"`
+		Bytes  int `json:"bytes"`
+		Stats  struct {
+			BetaReductions int64 `json:"beta_reductions"`
+		} `json:"stats"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(runCLI(t, "", "stats", "-format", "json", "-e", `(λx.(* x 2)) 4`)), &stats))
+	assert.Equal(t, 6, stats.Tokens)
+	assert.Equal(t, 17, stats.Bytes)
+	assert.Equal(t, int64(1), stats.Stats.BetaReductions)
+	assert.Contains(t, runCLI(t, "", "stats", "-max-steps", "2", "-e", `(λx.(* x 2)) 4`), "error: exceeded step budget of 2")
+
+	assert.Equal(t, "S()\n", runCLI(t, "", "minimize", "-q", "hi"))
+	long := strings.Repeat("L", 200)
+	program := strings.TrimSpace(runCLI(t, "", "minimize", "-q", "-e", `"`+long+`"`))
+	assert.Less(t, len(program), 150)
+	assert.Equal(t, long+"\n", runCLI(t, "", "decode", "-e", program))
+
+	var out bytes.Buffer
+	err := run([]string{"eval", "-e", `B$ L! B$ v! v! L! B$ v! v!`, "-max-steps", "100"}, strings.NewReader(""), &out)
+	assert.EqualError(t, err, "exceeded step budget of 100")
+	err = run([]string{"render", "-format", "yaml", "-e", `I!`}, strings.NewReader(""), &out)
+	assert.EqualError(t, err, `unknown format "yaml" (want text or json)`)
+}
+
+func TestCLIProfile(t *testing.T) {
+	out := runCLI(t, "", "profile", "-top", "1", "-e", `(λf.(f (f 3))) (λx.(* x 2))`)
+	assert.Contains(t, out, "14 steps, 3 beta reductions, 10 allocations in 2 lambdas\n")
+	assert.Contains(t, out, "(λx.(* x 2))")
+	assert.NotContains(t, out, "(λf.(f (f 3)))")
+
+	path := filepath.Join(t.TempDir(), "prof.pb.gz")
+	out = runCLI(t, "", "profile", "-max-steps", "5", "-o", path, "-e", `(λf.(f (f 3))) (λx.(* x 2))`)
+	assert.Contains(t, out, "error: exceeded step budget of 5")
+	assert.FileExists(t, path)
+}
+
+func TestCLICodec(t *testing.T) {
+	assert.Equal(t, "S(%,,/}Q/2,$\nhello World\n", runCLI(t, "", "echo", "hello", "World"))
+	assert.Equal(t, "hi\n", runCLI(t, "", "echo", "-q", "hi"))
+	assert.Equal(t, "1337\tI/6\t[14 21]\n-5\tU- I&\t[5]\n0\tI!\t[0]\n", runCLI(t, "", "int", "1337", "-5", "I!"))
+
+	var out bytes.Buffer
+	err := run([]string{"encode", "naïve"}, strings.NewReader(""), &out)
+	assert.EqualError(t, err, "unsupported character 'ï' (U+00EF) at line 1, column 3")
+	err = run([]string{"echo"}, strings.NewReader("ok\n\tá"), &out)
+	assert.EqualError(t, err, "2 unsupported characters:\n  unsupported character '\\t' (U+0009) at line 2, column 1\n  unsupported character 'á' (U+00E1) at line 2, column 2")
+	err = run([]string{"int", "12x"}, strings.NewReader(""), &out)
+	assert.EqualError(t, err, `"12x" is neither a decimal number nor an integer token`)
+}
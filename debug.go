@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/lukehoban/icfp2024/icfp"
+)
+
+const debugHelp = `  s, step          stop at the next reduction
+  n, next          stop at the next reduction at this depth or shallower
+  c, continue      run until a breakpoint
+  b var N          break on beta reductions binding variable N
+  b op X           break on operator X ($ for any application, ? for if)
+  d, delete        clear all breakpoints
+  p, print [N]     print the environment, or the thunk bound to N
+  l, list          show the current reduction again
+  q, quit          abort evaluation
+`
+
+var errDebugQuit = errors.New("quit")
+
+type debugMode int
+
+const (
+	modeStep debugMode = iota
+	modeNext
+	modeContinue
+)
+
+const maxDebugTerm = 200
+
+func shorten(s string) string {
+	if len(s) > maxDebugTerm {
+		return s[:maxDebugTerm] + "..."
+	}
+	return s
+}
+
+type debugger struct {
+	in        *bufio.Scanner
+	out       io.Writer
+	mode      debugMode
+	nextDepth int
+	breakVars map[int64]bool
+	breakOps  map[string]bool
+	count     int64
+}
+
+func newDebugger(in io.Reader, out io.Writer) *debugger {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	return &debugger{in: scanner, out: out, breakVars: map[int64]bool{}, breakOps: map[string]bool{}}
+}
+
+func (d *debugger) show(e icfp.Event) {
+	fmt.Fprintf(d.out, "#%d depth %d: %s\n", d.count, e.Depth, shorten(e.String()))
+	fmt.Fprintf(d.out, "  at %s\n", shorten(icfp.RenderAsLambda(e.Expr)))
+}
+
+func (d *debugger) printEnv(env icfp.Env, arg string) {
+	if arg != "" {
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(d.out, "bad variable number %q\n", arg)
+			return
+		}
+		thunk, ok := env[n]
+		if !ok {
+			fmt.Fprintf(d.out, "%s is not bound\n", icfp.VarName(n))
+			return
+		}
+		fmt.Fprintf(d.out, "%s = %s\n", icfp.VarName(n), thunk)
+		return
+	}
+	if len(env) == 0 {
+		fmt.Fprintf(d.out, "(empty environment)\n")
+	}
+	for _, v := range env.Vars() {
+		fmt.Fprintf(d.out, "%s = %s\n", icfp.VarName(v), shorten(env[v].String()))
+	}
+}
+
+func (d *debugger) breakpoint(arg string) error {
+	kind, val, _ := strings.Cut(arg, " ")
+	val = strings.TrimSpace(val)
+	switch kind {
+	case "var":
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad variable number %q", val)
+		}
+		d.breakVars[n] = true
+	case "op":
+		if val == "" {
+			return fmt.Errorf("usage: b op X")
+		}
+		d.breakOps[val] = true
+	default:
+		return fmt.Errorf("usage: b var N | b op X")
+	}
+	return nil
+}
+
+func (d *debugger) Trace(e icfp.Event) {
+	d.count++
+	stop := d.mode == modeStep || (d.mode == modeNext && e.Depth <= d.nextDepth)
+	if (e.Kind == icfp.EventBeta && d.breakVars[e.Lambda.Param]) || d.breakOps[e.Op] ||
+		(e.Kind == icfp.EventBeta && d.breakOps["$"]) {
+		fmt.Fprintf(d.out, "breakpoint\n")
+		stop = true
+	}
+	if !stop {
+		return
+	}
+	d.show(e)
+	for {
+		fmt.Fprint(d.out, "(debug) ")
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			d.mode = modeContinue
+			d.breakVars = map[int64]bool{}
+			d.breakOps = map[string]bool{}
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(d.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "", "s", "step":
+			d.mode = modeStep
+			return
+		case "n", "next":
+			d.mode = modeNext
+			d.nextDepth = e.Depth
+			return
+		case "c", "continue":
+			d.mode = modeContinue
+			return
+		case "b", "break":
+			if err := d.breakpoint(arg); err != nil {
+				fmt.Fprintf(d.out, "%v\n", err)
+			}
+		case "d", "delete":
+			d.breakVars = map[int64]bool{}
+			d.breakOps = map[string]bool{}
+		case "p", "print":
+			d.printEnv(e.Env, arg)
+		case "l", "list":
+			d.show(e)
+		case "q", "quit":
+			panic(errDebugQuit)
+		case "h", "help":
+			fmt.Fprint(d.out, debugHelp)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q, try help\n", cmd)
+		}
+	}
+}
+
+func runDebug(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	strategy := fs.String("strategy", "need", "application strategy: need, name or value")
+	program := fs.String("e", "", "program to debug, instead of reading it from a file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s := *program
+	if s == "" {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: debug [-strategy s] (-e program | file)")
+		}
+		byts, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		s = string(byts)
+	}
+	r := newRepl(stdout)
+	expr, err := r.parse(s)
+	if err != nil {
+		return err
+	}
+	ev := icfp.Evaluator{Tracer: newDebugger(stdin, stdout)}
+	ev.Strategy, err = icfp.ParseStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+	res, err := ev.Eval(expr, nil)
+	if errors.Is(err, errDebugQuit) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s\n", icfp.RenderAsLambda(res))
+	return nil
+}
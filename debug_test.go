@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lukehoban/icfp2024/icfp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugger(t *testing.T) {
+	expr, err := icfp.ParseLambda(`(λx.(+ x (* x 2))) (+ 1 2)`)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	d := newDebugger(strings.NewReader("b op *\nc\np\nq\n"), &out)
+	ev := icfp.Evaluator{Tracer: d}
+	_, err = ev.Eval(expr, nil)
+	assert.Equal(t, errDebugQuit, err)
+	assert.Equal(t, `#1 depth 1: beta x := (+ 1 2)
+  at ((λx.(+ x (* x 2))) (+ 1 2))
+(debug) (debug) breakpoint
+#3 depth 3: primitive * 3 2
+  at (* x 2)
+(debug) x = evaluated 3
+(debug) `, out.String())
+
+	out.Reset()
+	d = newDebugger(strings.NewReader("s\nn\n"), &out)
+	ev = icfp.Evaluator{Tracer: d}
+	res, err := ev.Eval(expr, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "9", icfp.RenderAsLambda(res))
+	assert.Equal(t, 3, strings.Count(out.String(), "(debug) "))
+	assert.Contains(t, out.String(), "#3 depth 3: primitive * 3 2")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lukehoban/icfp2024/icfp"
+)
+
+func runFetch(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	dir := fs.String("dir", icfp.DefaultProblemsDir, "directory to download problems into")
+	courses := fs.String("courses", "", "comma separated courses to fetch (default every course on the index)")
+	force := fs.Bool("force", false, "download problems again even if they exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := icfp.DefaultClient()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var names []string
+	if *courses != "" {
+		names = strings.Split(*courses, ",")
+	} else {
+		index, err := c.Get(ctx, "index")
+		if err != nil {
+			return err
+		}
+		names = icfp.ParsePage(index).Courses
+	}
+
+	failed := 0
+	for _, course := range names {
+		results, err := c.FetchProblems(ctx, *dir, strings.TrimSpace(course), *force)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", course, err)
+			failed++
+			continue
+		}
+		fetched, skipped := 0, 0
+		for _, r := range results {
+			switch {
+			case r.Err != nil:
+				fmt.Fprintf(stdout, "%s: %v\n", r.Problem.ID, r.Err)
+				failed++
+			case r.Skipped:
+				skipped++
+			default:
+				fetched++
+			}
+		}
+		fmt.Fprintf(stdout, "%s: fetched %d, skipped %d existing\n", course, fetched, skipped)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d downloads failed", failed)
+	}
+	return nil
+}
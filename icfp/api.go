@@ -0,0 +1,160 @@
+package icfp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const echoSuffix = "\n\nYou scored some points for using the echo service!"
+
+func (c *Client) Get(ctx context.Context, page string) (string, error) {
+	return c.CommunicateString(ctx, "get "+page)
+}
+
+// Echo returns what the echo service sent back, without its trailing note
+// about scoring points.
+func (c *Client) Echo(ctx context.Context, s string) (string, error) {
+	ret, err := c.CommunicateString(ctx, "echo "+s)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(ret, echoSuffix), nil
+}
+
+type SolveResult struct {
+	Success bool
+	Problem string
+	// Score is zero for problems the server doesn't score.
+	Score int
+	// Error is the server's reply when the solution wasn't accepted.
+	Error string
+	Raw   string
+	// Skipped is set when the answer wasn't sent because the ledger has a
+	// submission at least as good; Score is then that submission's score.
+	Skipped bool
+}
+
+var solvedRegexp = regexp.MustCompile(`^Correct, you solved (\S+?)(?: with a score of (\d+))?!`)
+
+func parseSolveResult(problem, raw string) *SolveResult {
+	res := &SolveResult{Problem: problem, Raw: raw}
+	m := solvedRegexp.FindStringSubmatch(raw)
+	if m == nil {
+		res.Error = raw
+		return res
+	}
+	res.Success = true
+	res.Problem = m[1]
+	if m[2] != "" {
+		res.Score, _ = strconv.Atoi(m[2])
+	}
+	return res
+}
+
+// Solve submits answer, sending the shortest of the plain encoding and any
+// candidate programs that evaluate to the same command.
+func (c *Client) Solve(ctx context.Context, problem, answer string, candidates ...string) (*SolveResult, error) {
+	return c.Submit(ctx, Submission{Problem: problem, Answer: answer}, candidates...)
+}
+
+// Submit solves sub.Problem with sub.Answer like Solve. If the client has a
+// Ledger, the submission is recorded there, and isn't sent at all unless its
+// LocalScore (by default, DefaultLocalScore) beats our best. If the
+// client has a Queue, the submission is saved there first, and stays there
+// to be sent by Queue.Drain if there's no reply.
+func (c *Client) Submit(ctx context.Context, sub Submission, candidates ...string) (*SolveResult, error) {
+	if err := ValidateText(sub.Answer); err != nil {
+		return nil, err
+	}
+	if len(candidates) > 0 {
+		// Candidates aren't kept in the queue, so they're used now.
+		sub.Encoded = ShortestEncoding(ctx, fmt.Sprintf("solve %s %s", sub.Problem, sub.Answer), candidates...)
+	}
+	if c.Queue == nil {
+		return c.submit(ctx, sub)
+	}
+	id, err := c.Queue.Add(sub)
+	if err != nil {
+		return nil, err
+	}
+	return c.Queue.send(ctx, c, id)
+}
+
+func (c *Client) submit(ctx context.Context, sub Submission) (*SolveResult, error) {
+	cmd := fmt.Sprintf("solve %s %s", sub.Problem, sub.Answer)
+	encoded := sub.Encoded
+	if encoded == "" {
+		encoded = ShortestEncoding(ctx, cmd)
+	}
+	if sub.LocalScore == 0 {
+		sub.LocalScore = DefaultLocalScore(sub.Problem, sub.Answer, encoded)
+	}
+	// Without a local score there's nothing to compare, so it's sent.
+	if c.Ledger != nil && sub.LocalScore > 0 && !c.Ledger.Better(sub.Problem, sub.LocalScore) {
+		best, _ := c.Ledger.Best(sub.Problem)
+		return &SolveResult{Success: true, Problem: sub.Problem, Score: best.Score(), Skipped: true}, nil
+	}
+	resp, err := c.communicate(ctx, cmd, func() string { return encoded })
+	if err != nil {
+		return nil, err
+	}
+	res := parseSolveResult(sub.Problem, resp.Text)
+	if c.Ledger != nil {
+		sub.Success, sub.ServerScore, sub.Response = res.Success, res.Score, resp.Text
+		if err := c.Ledger.Record(sub); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+type Test3DResult struct {
+	// Output is the submitted value, empty if the program didn't submit one.
+	Output string
+	// Trace holds the rendered boards, each starting with a "[t=..." line.
+	Trace  []string
+	Volume int64
+	Error  string
+	Raw    string
+}
+
+func parseTest3DResult(raw string) *Test3DResult {
+	res := &Test3DResult{Raw: raw}
+	known := false
+	for _, block := range strings.Split(raw, "\n\n") {
+		if strings.HasPrefix(block, "[t=") {
+			res.Trace = append(res.Trace, block)
+			known = true
+			continue
+		}
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "Output: "):
+				res.Output = strings.TrimPrefix(line, "Output: ")
+			case strings.HasPrefix(line, "Error: "):
+				res.Error = strings.TrimPrefix(line, "Error: ")
+			case strings.HasPrefix(line, "Volume: "):
+				res.Volume, _ = strconv.ParseInt(strings.TrimPrefix(line, "Volume: "), 10, 64)
+			default:
+				continue
+			}
+			known = true
+		}
+	}
+	if !known {
+		res.Error = raw
+	}
+	return res
+}
+
+// Test3D runs program with inputs a and b using the `test 3d` command.
+func (c *Client) Test3D(ctx context.Context, a, b int64, program string) (*Test3DResult, error) {
+	raw, err := c.CommunicateString(ctx, fmt.Sprintf("test 3d %d %d\n%s", a, b, program))
+	if err != nil {
+		return nil, err
+	}
+	return parseTest3DResult(raw), nil
+}
@@ -0,0 +1,81 @@
+package icfp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSolveResult(t *testing.T) {
+	assert.Equal(t, &SolveResult{Success: true, Problem: "spaceship1", Score: 5, Raw: "Correct, you solved spaceship1 with a score of 5!"},
+		parseSolveResult("spaceship1", "Correct, you solved spaceship1 with a score of 5!"))
+	assert.Equal(t, &SolveResult{Success: true, Problem: "hello4", Raw: "Correct, you solved hello4!"},
+		parseSolveResult("language_test", "Correct, you solved hello4!"))
+	assert.Equal(t, &SolveResult{Problem: "lambdaman1", Error: "Your solution for lambdaman1 is incorrect", Raw: "Your solution for lambdaman1 is incorrect"},
+		parseSolveResult("lambdaman1", "Your solution for lambdaman1 is incorrect"))
+}
+
+func TestParseTest3DResult(t *testing.T) {
+	res := parseTest3DResult("[t=1, x=0, y=0]\n. 2 .\n3 / S\n\n[t=2, x=0, y=0]\n. . .\n. / 1\n. 1 .\n\nOutput: 1\nVolume: 6")
+	assert.Equal(t, "1", res.Output)
+	assert.Equal(t, int64(6), res.Volume)
+	assert.Equal(t, []string{"[t=1, x=0, y=0]\n. 2 .\n3 / S", "[t=2, x=0, y=0]\n. . .\n. / 1\n. 1 ."}, res.Trace)
+	assert.Empty(t, res.Error)
+
+	res = parseTest3DResult("[t=1, x=0, y=0]\n1 > .\n\nError: no operator can reduce\nVolume: 3")
+	assert.Equal(t, "no operator can reduce", res.Error)
+	assert.Empty(t, res.Output)
+
+	res = parseTest3DResult("Something new")
+	assert.Equal(t, "Something new", res.Error)
+	assert.Equal(t, "Something new", res.Raw)
+}
+
+func TestClientTypedAPI(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		cmd := DecodeRequest(string(body))
+		got = append(got, cmd)
+		reply := "Unknown"
+		switch {
+		case strings.HasPrefix(cmd, "get "):
+			reply = "page " + cmd[4:]
+		case strings.HasPrefix(cmd, "echo "):
+			reply = cmd[5:] + echoSuffix
+		case strings.HasPrefix(cmd, "solve "):
+			reply = "Correct, you solved spaceship1 with a score of 5!"
+		case strings.HasPrefix(cmd, "test 3d "):
+			reply = "Output: 12\nVolume: 320"
+		}
+		io.WriteString(w, string(StringToToken(reply)))
+	}))
+	defer server.Close()
+	c := &Client{BaseURL: server.URL, Token: "secret", HTTPClient: server.Client()}
+	ctx := context.Background()
+
+	s, err := c.Get(ctx, "spaceship")
+	assert.NoError(t, err)
+	assert.Equal(t, "page spaceship", s)
+
+	s, err = c.Echo(ctx, "hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", s)
+
+	solve, err := c.Solve(ctx, "spaceship1", "31619")
+	assert.NoError(t, err)
+	assert.True(t, solve.Success)
+	assert.Equal(t, 5, solve.Score)
+
+	test, err := c.Test3D(ctx, 3, 4, ". A .\n. S .")
+	assert.NoError(t, err)
+	assert.Equal(t, "12", test.Output)
+	assert.Equal(t, int64(320), test.Volume)
+
+	assert.Equal(t, []string{"get spaceship", "echo hi", "solve spaceship1 31619", "test 3d 3 4\n. A .\n. S ."}, got)
+}
@@ -0,0 +1,98 @@
+package icfp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// benchCases are the programs in testdata/bench and what they evaluate to.
+// The efficiency programs are from the README, with their inputs scaled down
+// so that each runs in well under a second. language_test is only served to
+// teams with a token, so it is optional: save the body of
+// `get language_test` as testdata/bench/language_test.txt to include it.
+// operators exercises every operator either way.
+var benchCases = []struct {
+	name   string
+	result string
+}{
+	{"language_test", `"Self-check OK, send ` + "`solve language_test 4w3s0m3`" + ` to claim points for it"`},
+	{"efficiency1", "17592186044416"},
+	{"efficiency2", "2134"},
+	{"efficiency3", "12135"},
+	{"efficiency4", "10946"},
+	{"efficiency5", "127"},
+	{"efficiency6", "22"},
+	{"operators", "true"},
+	{"deep_recursion", "200010000"},
+	{"string_building", `"` + strings.Repeat("ab", 5000) + `"`},
+}
+
+// optionalBenchCases are left out when their program isn't checked in.
+var optionalBenchCases = map[string]bool{"language_test": true}
+
+func skipBenchCase(name string) bool {
+	_, err := os.Stat(filepath.Join("testdata", "bench", name+".txt"))
+	return optionalBenchCases[name] && errors.Is(err, os.ErrNotExist)
+}
+
+func loadBenchCase(tb testing.TB, name string) Expr {
+	byts, err := os.ReadFile(filepath.Join("testdata", "bench", name+".txt"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := strings.TrimSpace(string(byts))
+	if tokens, err := parseSafe(s); err == nil {
+		if expr, rest := combinePartial(tokens); len(rest) == 0 && Check(expr) == nil {
+			return expr
+		}
+	}
+	expr, err := ParseLambda(s)
+	if err != nil {
+		tb.Fatalf("%s: %v", name, err)
+	}
+	return expr
+}
+
+func TestBenchCorpus(t *testing.T) {
+	for _, c := range benchCases {
+		if skipBenchCase(c.name) {
+			continue
+		}
+		ev := &Evaluator{MaxSteps: 10000000}
+		res, err := ev.Eval(loadBenchCase(t, c.name), nil)
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.result, RenderAsLambda(res), c.name)
+	}
+}
+
+func benchmarkEval(b *testing.B, expr Expr, strategy Strategy) {
+	b.ReportAllocs()
+	var stats Stats
+	for i := 0; i < b.N; i++ {
+		ev := &Evaluator{Strategy: strategy}
+		if _, err := ev.Eval(expr, nil); err != nil {
+			b.Fatal(err)
+		}
+		stats = ev.Stats
+	}
+	b.ReportMetric(float64(stats.Steps), "steps/op")
+	b.ReportMetric(float64(stats.BetaReductions), "betas/op")
+	b.ReportMetric(float64(stats.MaxDepth), "depth/op")
+}
+
+func BenchmarkEval(b *testing.B) {
+	for _, c := range benchCases {
+		if skipBenchCase(c.name) {
+			continue
+		}
+		expr := loadBenchCase(b, c.name)
+		b.Run(c.name, func(b *testing.B) {
+			benchmarkEval(b, expr, CallByNeed)
+		})
+	}
+}
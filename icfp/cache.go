@@ -0,0 +1,124 @@
+package icfp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache stores decoded responses to `get` commands for problem inputs on
+// disk, one JSON file per command named by the SHA-256 of the command.
+type Cache struct {
+	Dir string
+	// MaxAge makes older entries count as missing; zero keeps them forever.
+	MaxAge time.Duration
+}
+
+type CacheEntry struct {
+	Command  string    `json:"command"`
+	Response string    `json:"response"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+// DefaultCacheDir is $ICFP_CACHE, or icfp2024 in the user cache directory.
+func DefaultCacheDir() string {
+	if d := os.Getenv("ICFP_CACHE"); d != "" {
+		return d
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "icfp2024")
+}
+
+// Cacheable reports whether command fetches a problem input, such as
+// `get lambdaman4`, which never changes. Other pages, like the index,
+// scoreboard and course pages with our scores, are always fetched.
+func Cacheable(command string) bool {
+	page, ok := strings.CutPrefix(command, "get ")
+	if !ok {
+		return false
+	}
+	course := strings.TrimRight(page, "0123456789")
+	return course != page && course != "" && !strings.ContainsAny(course, " \n")
+}
+
+func (c *Cache) path(command string) string {
+	sum := sha256.Sum256([]byte(command))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) Get(command string) (*CacheEntry, bool, error) {
+	byts, err := os.ReadFile(c.path(command))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var entry CacheEntry
+	if err := json.Unmarshal(byts, &entry); err != nil {
+		return nil, false, err
+	}
+	if entry.Command != command {
+		return nil, false, nil
+	}
+	if c.MaxAge > 0 && time.Since(entry.Fetched) > c.MaxAge {
+		return &entry, false, nil
+	}
+	return &entry, true, nil
+}
+
+func (c *Cache) Put(command, response string) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	byts, err := json.MarshalIndent(CacheEntry{Command: command, Response: response, Fetched: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.Dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(byts); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(command))
+}
+
+type CacheMode int
+
+const (
+	// CacheDefault serves cached responses and stores new ones.
+	CacheDefault CacheMode = iota
+	// CacheRefresh always fetches, then stores the new response.
+	CacheRefresh
+	// CacheBypass neither reads nor writes the cache.
+	CacheBypass
+)
+
+type cacheModeKey struct{}
+
+// WithCacheMode sets how requests made with ctx use the client's cache.
+func WithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+func cacheMode(ctx context.Context) CacheMode {
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode
+}
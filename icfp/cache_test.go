@@ -0,0 +1,78 @@
+package icfp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.WriteString(w, string(StringToToken("page")))
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL, Token: "secret", Cache: &Cache{Dir: t.TempDir()}}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		s, err := c.CommunicateString(ctx, "get lambdaman1")
+		assert.NoError(t, err)
+		assert.Equal(t, "page", s)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int64(1), c.Metrics.CacheHits.Load())
+
+	_, err := c.CommunicateString(WithCacheMode(ctx, CacheRefresh), "get lambdaman1")
+	assert.NoError(t, err)
+	_, err = c.CommunicateString(WithCacheMode(ctx, CacheBypass), "get lambdaman1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Only problem inputs are cached; course pages change as we solve them.
+	for _, cmd := range []string{"echo hi", "echo hi", "get lambdaman", "get lambdaman"} {
+		_, err = c.CommunicateString(ctx, cmd)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(7), calls.Load())
+	assert.True(t, Cacheable("get 3d12"))
+	assert.False(t, Cacheable("get 3d"))
+	assert.False(t, Cacheable("get scoreboard"))
+	assert.False(t, Cacheable("get 12"))
+
+	// Cached pages are served without a token.
+	c.Token = ""
+	s, err := c.CommunicateString(ctx, "get lambdaman1")
+	assert.NoError(t, err)
+	assert.Equal(t, "page", s)
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	assert.NoError(t, c.Put("get index", "hello"))
+	entry, ok, err := c.Get("get index")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", entry.Response)
+
+	stale, _ := json.Marshal(CacheEntry{Command: "get index", Response: "old", Fetched: time.Now().Add(-2 * time.Hour)})
+	assert.NoError(t, os.WriteFile(c.path("get index"), stale, 0644))
+	c.MaxAge = time.Hour
+	entry, ok, err = c.Get("get index")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "old", entry.Response)
+
+	_, ok, err = c.Get("get spaceship")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,467 @@
+package icfp
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Diagnostic struct {
+	Kind    string
+	Message string
+	Term    string
+}
+
+func (d Diagnostic) String() string {
+	if d.Term == "" {
+		return fmt.Sprintf("%s: %s", d.Kind, d.Message)
+	}
+	return fmt.Sprintf("%s: %s in %s", d.Kind, d.Message, d.Term)
+}
+
+const (
+	DiagFreeVariable = "free-variable"
+	DiagShape        = "shape"
+	DiagType         = "type"
+)
+
+// Type is the result of inference over the ICFP term language: int, bool,
+// string, functions and (unresolved) type variables.
+type Type interface {
+	String() string
+}
+
+type TInt struct{}
+type TBool struct{}
+type TString struct{}
+type TFunc struct {
+	Arg    Type
+	Result Type
+}
+type TVar struct {
+	id    int
+	bound Type
+}
+
+func (TInt) String() string    { return "int" }
+func (TBool) String() string   { return "bool" }
+func (TString) String() string { return "string" }
+func (f TFunc) String() string {
+	arg := prune(f.Arg)
+	if _, ok := arg.(TFunc); ok {
+		return fmt.Sprintf("(%s) -> %s", arg, prune(f.Result))
+	}
+	return fmt.Sprintf("%s -> %s", arg, prune(f.Result))
+}
+func (v *TVar) String() string {
+	if v.bound != nil {
+		return v.bound.String()
+	}
+	if v.id < 26 {
+		return "'" + string(rune('a'+v.id))
+	}
+	return fmt.Sprintf("'t%d", v.id)
+}
+
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*TVar)
+		if !ok || v.bound == nil {
+			return t
+		}
+		t = v.bound
+	}
+}
+
+const maxDiagnosticTerm = 120
+
+func renderTerm(e Expr) string {
+	if e == nil {
+		return ""
+	}
+	return truncate(RenderAsLambda(e), maxDiagnosticTerm)
+}
+
+type scheme struct {
+	vars []*TVar
+	t    Type
+}
+
+type checker struct {
+	diags []Diagnostic
+	next  int
+}
+
+func (c *checker) report(kind string, e Expr, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{Kind: kind, Message: fmt.Sprintf(format, args...), Term: renderTerm(e)})
+}
+
+func (c *checker) fresh() *TVar {
+	v := &TVar{id: c.next}
+	c.next++
+	return v
+}
+
+func (c *checker) occurs(v *TVar, t Type) bool {
+	switch t := prune(t).(type) {
+	case *TVar:
+		return t == v
+	case TFunc:
+		return c.occurs(v, t.Arg) || c.occurs(v, t.Result)
+	}
+	return false
+}
+
+func (c *checker) unify(a, b Type) error {
+	a, b = prune(a), prune(b)
+	if va, ok := a.(*TVar); ok {
+		if vb, ok := b.(*TVar); ok && va == vb {
+			return nil
+		}
+		if c.occurs(va, b) {
+			return fmt.Errorf("infinite type %s = %s", va, b)
+		}
+		va.bound = b
+		return nil
+	}
+	if _, ok := b.(*TVar); ok {
+		return c.unify(b, a)
+	}
+	fa, oka := a.(TFunc)
+	fb, okb := b.(TFunc)
+	if oka && okb {
+		if err := c.unify(fa.Arg, fb.Arg); err != nil {
+			return err
+		}
+		return c.unify(fa.Result, fb.Result)
+	}
+	if a == b {
+		return nil
+	}
+	return fmt.Errorf("cannot unify %s with %s", a, b)
+}
+
+func (c *checker) expect(e Expr, got, want Type) {
+	if err := c.unify(got, want); err != nil {
+		c.report(DiagType, e, "%v", err)
+	}
+}
+
+func (c *checker) instantiate(s scheme) Type {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+	subst := map[*TVar]Type{}
+	for _, v := range s.vars {
+		subst[v] = c.fresh()
+	}
+	var inst func(t Type) Type
+	inst = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *TVar:
+			if r, ok := subst[t]; ok {
+				return r
+			}
+			return t
+		case TFunc:
+			return TFunc{inst(t.Arg), inst(t.Result)}
+		default:
+			return t
+		}
+	}
+	return inst(s.t)
+}
+
+func freeTypeVars(t Type, acc map[*TVar]bool) {
+	switch t := prune(t).(type) {
+	case *TVar:
+		acc[t] = true
+	case TFunc:
+		freeTypeVars(t.Arg, acc)
+		freeTypeVars(t.Result, acc)
+	}
+}
+
+func (c *checker) generalize(t Type, env map[int64]scheme) scheme {
+	inEnv := map[*TVar]bool{}
+	for _, s := range env {
+		freeTypeVars(s.t, inEnv)
+	}
+	free := map[*TVar]bool{}
+	freeTypeVars(t, free)
+	var vars []*TVar
+	for v := range free {
+		if !inEnv[v] {
+			vars = append(vars, v)
+		}
+	}
+	return scheme{vars: vars, t: t}
+}
+
+func isSelfApp(e Expr, x int64) bool {
+	b, ok := e.(Binop)
+	if !ok || b.Op != "$" {
+		return false
+	}
+	l, okl := b.Left.(Var)
+	r, okr := b.Right.(Var)
+	return okl && okr && l.v == x && r.v == x
+}
+
+// isFixpointHalf matches λx.f (x x) and its eta-expanded call-by-value form
+// λx.f (λv.x x v).
+func isFixpointHalf(e Expr, f int64) bool {
+	l, ok := e.(Lambda)
+	if !ok || l.Param == f {
+		return false
+	}
+	app, ok := l.Body.(Binop)
+	if !ok || app.Op != "$" {
+		return false
+	}
+	if fv, ok := app.Left.(Var); !ok || fv.v != f {
+		return false
+	}
+	if isSelfApp(app.Right, l.Param) {
+		return true
+	}
+	eta, ok := app.Right.(Lambda)
+	if !ok || eta.Param == l.Param {
+		return false
+	}
+	inner, ok := eta.Body.(Binop)
+	if !ok || inner.Op != "$" {
+		return false
+	}
+	v, ok := inner.Right.(Var)
+	return ok && v.v == eta.Param && isSelfApp(inner.Left, l.Param)
+}
+
+// IsFixpoint reports whether e is a Y or Z combinator, which cannot be typed
+// directly because of the self application.
+func IsFixpoint(e Expr) bool {
+	l, ok := e.(Lambda)
+	if !ok {
+		return false
+	}
+	app, ok := l.Body.(Binop)
+	if !ok || app.Op != "$" {
+		return false
+	}
+	return isFixpointHalf(app.Left, l.Param) && isFixpointHalf(app.Right, l.Param)
+}
+
+func extend(env map[int64]scheme, k int64, s scheme) map[int64]scheme {
+	newEnv := make(map[int64]scheme, len(env)+1)
+	for k, v := range env {
+		newEnv[k] = v
+	}
+	newEnv[k] = s
+	return newEnv
+}
+
+func isApply(op string) bool {
+	return op == "$" || op == "~" || op == "!"
+}
+
+func (c *checker) infer(e Expr, env map[int64]scheme) Type {
+	switch v := e.(type) {
+	case nil:
+		return c.fresh()
+	case Integer:
+		if v.Int == nil {
+			c.report(DiagShape, e, "integer without value")
+		} else if v.Sign() < 0 {
+			c.report(DiagShape, e, "negative integer literal")
+		}
+		return TInt{}
+	case Boolean:
+		return TBool{}
+	case String:
+		return TString{}
+	case Var:
+		if v.v < 0 {
+			c.report(DiagShape, e, "negative variable number")
+		}
+		s, ok := env[v.v]
+		if !ok {
+			c.report(DiagFreeVariable, e, "unbound variable %s", RenderAsLambda(v))
+			return c.fresh()
+		}
+		return c.instantiate(s)
+	case Lambda:
+		if IsFixpoint(v) {
+			a := c.fresh()
+			return TFunc{TFunc{a, a}, a}
+		}
+		if v.Body == nil {
+			c.report(DiagShape, e, "lambda without body")
+		}
+		if v.Param < 0 {
+			c.report(DiagShape, e, "negative variable number")
+		}
+		arg := c.fresh()
+		body := c.infer(v.Body, extend(env, v.Param, scheme{t: arg}))
+		return TFunc{arg, body}
+	case If:
+		if v.Test == nil || v.Then == nil || v.Else == nil {
+			c.report(DiagShape, e, "if expects 3 operands")
+		}
+		c.expect(v.Test, c.infer(v.Test, env), TBool{})
+		then := c.infer(v.Then, env)
+		els := c.infer(v.Else, env)
+		c.expect(e, els, then)
+		return then
+	case Unop:
+		if v.Arg == nil {
+			c.report(DiagShape, e, "unary %q expects 1 operand", v.Op)
+		}
+		arg := c.infer(v.Arg, env)
+		switch v.Op {
+		case "-":
+			c.expect(e, arg, TInt{})
+			return TInt{}
+		case "!":
+			c.expect(e, arg, TBool{})
+			return TBool{}
+		case "#":
+			c.expect(e, arg, TString{})
+			return TInt{}
+		case "$":
+			c.expect(e, arg, TInt{})
+			return TString{}
+		default:
+			c.report(DiagShape, e, "unknown unary operator %q", v.Op)
+			return c.fresh()
+		}
+	case Binop:
+		if v.Left == nil || v.Right == nil {
+			c.report(DiagShape, e, "binary %q expects 2 operands", v.Op)
+		}
+		if isApply(v.Op) {
+			if l, ok := v.Left.(Lambda); ok && v.Right != nil && !IsFixpoint(l) {
+				// A redex binds like let, so its argument may be used polymorphically.
+				arg := c.infer(v.Right, env)
+				return c.infer(l.Body, extend(env, l.Param, c.generalize(arg, env)))
+			}
+			fn := c.infer(v.Left, env)
+			arg := c.infer(v.Right, env)
+			res := c.fresh()
+			c.expect(e, fn, TFunc{arg, res})
+			return res
+		}
+		left := c.infer(v.Left, env)
+		right := c.infer(v.Right, env)
+		switch v.Op {
+		case "+", "-", "*", "/", "%":
+			c.expect(v.Left, left, TInt{})
+			c.expect(v.Right, right, TInt{})
+			return TInt{}
+		case "<", ">":
+			c.expect(v.Left, left, TInt{})
+			c.expect(v.Right, right, TInt{})
+			return TBool{}
+		case "=":
+			c.expect(e, right, left)
+			if _, ok := prune(left).(TFunc); ok {
+				c.report(DiagType, e, "cannot compare functions")
+			}
+			return TBool{}
+		case "&", "|":
+			c.expect(v.Left, left, TBool{})
+			c.expect(v.Right, right, TBool{})
+			return TBool{}
+		case ".":
+			c.expect(v.Left, left, TString{})
+			c.expect(v.Right, right, TString{})
+			return TString{}
+		case "T", "D":
+			c.expect(v.Left, left, TInt{})
+			c.expect(v.Right, right, TString{})
+			return TString{}
+		default:
+			c.report(DiagShape, e, "unknown binary operator %q", v.Op)
+			return c.fresh()
+		}
+	default:
+		c.report(DiagShape, nil, "unknown term %T", e)
+		return c.fresh()
+	}
+}
+
+// Infer returns the principal type of e along with any diagnostics found.
+func Infer(e Expr) (Type, []Diagnostic) {
+	c := &checker{}
+	if e == nil {
+		c.report(DiagShape, nil, "empty program")
+		return c.fresh(), c.diags
+	}
+	t := c.infer(e, map[int64]scheme{})
+	return prune(t), c.diags
+}
+
+// Check reports free variables, malformed terms and type errors in e.
+func Check(e Expr) []Diagnostic {
+	_, diags := Infer(e)
+	return diags
+}
+
+// CheckString parses an ICFP program and checks it, reporting bad tokens,
+// missing operands and trailing input instead of panicking.
+func CheckString(s string) []Diagnostic {
+	var diags []Diagnostic
+	var exprs []Expr
+	for i, token := range strings.Fields(s) {
+		expr, err := parseTokenSafe(token)
+		if err != nil {
+			diags = append(diags, Diagnostic{Kind: DiagShape, Message: fmt.Sprintf("token %d: %v", i, err)})
+			continue
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) == 0 {
+		return append(diags, Diagnostic{Kind: DiagShape, Message: "empty program"})
+	}
+	expr, rest := combinePartial(exprs)
+	if len(rest) > 0 {
+		diags = append(diags, Diagnostic{Kind: DiagShape, Message: fmt.Sprintf("%d unused trailing tokens", len(rest))})
+	}
+	return append(diags, Check(expr)...)
+}
+
+func parseTokenSafe(token string) (expr Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return ParseToken(token), nil
+}
+
+// combinePartial is CombineToExpr, but leaves nil operands where the input
+// runs out.
+func combinePartial(exprs []Expr) (Expr, []Expr) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	expr := exprs[0]
+	exprs = exprs[1:]
+	switch v := expr.(type) {
+	case If:
+		test, exprs := combinePartial(exprs)
+		then, exprs := combinePartial(exprs)
+		els, exprs := combinePartial(exprs)
+		return If{test, then, els}, exprs
+	case Binop:
+		left, exprs := combinePartial(exprs)
+		right, exprs := combinePartial(exprs)
+		return Binop{v.Op, left, right}, exprs
+	case Lambda:
+		body, exprs := combinePartial(exprs)
+		return Lambda{Param: v.Param, Body: body}, exprs
+	case Unop:
+		arg, exprs := combinePartial(exprs)
+		return Unop{v.Op, arg}, exprs
+	default:
+		return v, exprs
+	}
+}
@@ -0,0 +1,80 @@
+package icfp
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkString(t *testing.T, s string) (Type, []Diagnostic) {
+	expr, rest := CombineToExpr(Parse(s))
+	assert.Empty(t, rest)
+	return Infer(expr)
+}
+
+func TestCheckWellTyped(t *testing.T) {
+	typ, diags := checkString(t, `B$ L# B+ v# v# I$`)
+	assert.Empty(t, diags)
+	assert.Equal(t, "int", typ.String())
+
+	typ, diags = checkString(t, `? B> I# I$ S9%3 S./`)
+	assert.Empty(t, diags)
+	assert.Equal(t, "string", typ.String())
+
+	typ, diags = checkString(t, `L# L$ v#`)
+	assert.Empty(t, diags)
+	assert.Equal(t, "'a -> 'b -> 'a", typ.String())
+}
+
+func TestCheckFixpoint(t *testing.T) {
+	typ, diags := checkString(t, `B+ I7c B* B$ B$ L" B$ L# B$ v" B$ v# v# L# B$ v" B$ v# v# L$ L% ? B= v% I! I" B+ I" B$ v$ B- v% I" I":c1+0 I!`)
+	assert.Empty(t, diags)
+	assert.Equal(t, "int", typ.String())
+}
+
+func TestCheckPolymorphicLet(t *testing.T) {
+	// (λf. (f 1) = (f 2) & (f true)) (λx.x)
+	_, diags := checkString(t, `B$ L" B& B= B$ v" I" B$ v" I# B$ v" T L# v#`)
+	assert.Empty(t, diags)
+}
+
+func TestCheckErrors(t *testing.T) {
+	_, diags := checkString(t, `B+ I" S#`)
+	assert.Len(t, diags, 1)
+	assert.Equal(t, DiagType, diags[0].Kind)
+	assert.Equal(t, "cannot unify string with int", diags[0].Message)
+	assert.Equal(t, `"c"`, diags[0].Term)
+
+	_, diags = checkString(t, `L# B+ v# v$`)
+	assert.Len(t, diags, 1)
+	assert.Equal(t, DiagFreeVariable, diags[0].Kind)
+	assert.Equal(t, "w", diags[0].Term)
+
+	_, diags = checkString(t, `L# B$ v# v#`)
+	assert.Len(t, diags, 1)
+	assert.Contains(t, diags[0].Message, "infinite type")
+
+	// Long terms are cut short without splitting a λ.
+	_, diags = checkString(t, "B+ I\" L#! "+strings.Repeat("L# ", 100)+"v#")
+	assert.Len(t, diags, 1)
+	assert.True(t, strings.HasSuffix(diags[0].Term, "..."))
+	assert.True(t, utf8.ValidString(diags[0].Term), diags[0].Term)
+}
+
+func TestCheckString(t *testing.T) {
+	diags := CheckString(`B+ I"`)
+	assert.Len(t, diags, 1)
+	assert.Equal(t, DiagShape, diags[0].Kind)
+	assert.Equal(t, `binary "+" expects 2 operands`, diags[0].Message)
+	assert.Equal(t, "(+ 1 _)", diags[0].Term)
+
+	diags = CheckString(`I" I#`)
+	assert.Len(t, diags, 1)
+	assert.Equal(t, "1 unused trailing tokens", diags[0].Message)
+
+	diags = CheckString(`B% I" X`)
+	assert.Equal(t, DiagShape, diags[0].Kind)
+	assert.Contains(t, diags[0].Message, "Unknown token: X")
+}
@@ -0,0 +1,187 @@
+package icfp
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Integer and string tokens are written with the 94 printable ASCII
+// characters from '!' to '~'. An integer's characters are its base 94
+// digits, most significant first, and a string's characters index lookup.
+const (
+	firstDigit = '!'
+	lastDigit  = '~'
+	base       = 94
+)
+
+// lookupIndex maps a character of lookup to its index, and anything else to
+// -1.
+var lookupIndex [256]int8
+
+func init() {
+	for i := range lookupIndex {
+		lookupIndex[i] = -1
+	}
+	for i := 0; i < len(lookup); i++ {
+		lookupIndex[lookup[i]] = int8(i)
+	}
+}
+
+// chunkDigits base 94 digits fit in a uint64, which EncodeInt and DecodeInt
+// use to do most of their arithmetic without big.Int.
+const chunkDigits = 9
+
+var (
+	chunkBase  = new(big.Int).Exp(big.NewInt(base), big.NewInt(chunkDigits), nil)
+	chunkScale = chunkBase.Uint64()
+)
+
+// EncodeInt returns the body of the integer token for n, which must not be
+// negative.
+func EncodeInt(n *big.Int) (string, error) {
+	if n.Sign() < 0 {
+		return "", fmt.Errorf("can't encode negative integer %s", n)
+	}
+	if n.IsUint64() && n.Uint64() < base {
+		return string(rune(firstDigit + n.Uint64())), nil
+	}
+	// Digits are produced least significant first and reversed at the end.
+	var digits []byte
+	q, r := new(big.Int).Set(n), new(big.Int)
+	for q.Sign() > 0 {
+		q.QuoRem(q, chunkBase, r)
+		chunk := r.Uint64()
+		for i := 0; i < chunkDigits && (chunk > 0 || q.Sign() > 0); i++ {
+			digits = append(digits, byte(firstDigit+chunk%base))
+			chunk /= base
+		}
+	}
+	for l, r := 0, len(digits)-1; l < r; l, r = l+1, r-1 {
+		digits[l], digits[r] = digits[r], digits[l]
+	}
+	return string(digits), nil
+}
+
+// DecodeInt parses the body of an integer token.
+func DecodeInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty integer")
+	}
+	n := new(big.Int)
+	chunk, scale := uint64(0), uint64(1)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < firstDigit || c > lastDigit {
+			return nil, fmt.Errorf("invalid integer digit %q at offset %d", c, i)
+		}
+		chunk = chunk*base + uint64(c-firstDigit)
+		scale *= base
+		if scale == chunkScale {
+			n.Mul(n, chunkBase).Add(n, new(big.Int).SetUint64(chunk))
+			chunk, scale = 0, 1
+		}
+	}
+	if scale > 1 {
+		n.Mul(n, new(big.Int).SetUint64(scale)).Add(n, new(big.Int).SetUint64(chunk))
+	}
+	return n, nil
+}
+
+// EncodeString returns the body of the string token for s, or a *TextError
+// if s has characters that strings can't hold.
+func EncodeString(s string) (string, error) {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		idx := lookupIndex[s[i]]
+		if idx < 0 {
+			return "", ValidateText(s)
+		}
+		b.WriteByte(byte(firstDigit + idx))
+	}
+	return b.String(), nil
+}
+
+// DecodeString returns the text of the body of a string token.
+func DecodeString(s string) (string, error) {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < firstDigit || c > lastDigit {
+			return "", fmt.Errorf("invalid string character %q at offset %d", c, i)
+		}
+		b.WriteByte(lookup[c-firstDigit])
+	}
+	return b.String(), nil
+}
+
+// translator streams bytes through a byte-for-byte mapping.
+type translator struct {
+	w      io.Writer
+	r      io.Reader
+	offset int
+	fn     func(c byte, offset int) (byte, error)
+}
+
+func (t *translator) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	for i, c := range p {
+		out, err := t.fn(c, t.offset+i)
+		if err != nil {
+			n, werr := t.w.Write(buf[:i])
+			t.offset += n
+			if werr != nil {
+				return n, werr
+			}
+			return n, err
+		}
+		buf[i] = out
+	}
+	n, err := t.w.Write(buf)
+	t.offset += n
+	return n, err
+}
+
+func (t *translator) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	for i := 0; i < n; i++ {
+		out, terr := t.fn(p[i], t.offset+i)
+		if terr != nil {
+			t.offset += i
+			return i, terr
+		}
+		p[i] = out
+	}
+	t.offset += n
+	return n, err
+}
+
+func encodeByte(c byte, offset int) (byte, error) {
+	idx := lookupIndex[c]
+	if idx < 0 {
+		return 0, fmt.Errorf("unsupported character %q at offset %d", c, offset)
+	}
+	return byte(firstDigit + idx), nil
+}
+
+func decodeByte(c byte, offset int) (byte, error) {
+	if c < firstDigit || c > lastDigit {
+		return 0, fmt.Errorf("invalid string character %q at offset %d", c, offset)
+	}
+	return lookup[c-firstDigit], nil
+}
+
+// NewStringEncoder returns a writer that writes text to w as the body of a
+// string token, for texts too big to hold twice in memory.
+func NewStringEncoder(w io.Writer) io.Writer {
+	return &translator{w: w, fn: encodeByte}
+}
+
+// NewStringDecoder returns a reader of the text of the string token body
+// read from r.
+func NewStringDecoder(r io.Reader) io.Reader {
+	return &translator{r: r, fn: decodeByte}
+}
@@ -0,0 +1,158 @@
+package icfp
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec(t *testing.T) {
+	s, err := EncodeInt(big.NewInt(1337))
+	assert.NoError(t, err)
+	assert.Equal(t, "/6", s)
+	s, err = EncodeInt(big.NewInt(0))
+	assert.NoError(t, err)
+	assert.Equal(t, "!", s)
+	_, err = EncodeInt(big.NewInt(-1))
+	assert.EqualError(t, err, "can't encode negative integer -1")
+
+	n, err := DecodeInt("/6")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1337), n.Int64())
+	_, err = DecodeInt("")
+	assert.EqualError(t, err, "empty integer")
+	_, err = DecodeInt("/ 6")
+	assert.EqualError(t, err, `invalid integer digit ' ' at offset 1`)
+
+	s, err = EncodeString("get index")
+	assert.NoError(t, err)
+	assert.Equal(t, "'%4}).$%8", s)
+	_, err = EncodeString("tab\there")
+	assert.EqualError(t, err, `unsupported character '\t' (U+0009) at line 1, column 4`)
+
+	s, err = DecodeString("'%4}).$%8")
+	assert.NoError(t, err)
+	assert.Equal(t, "get index", s)
+	_, err = DecodeString("'%\x7f")
+	assert.EqualError(t, err, `invalid string character '\x7f' at offset 2`)
+
+	assert.Panics(t, func() { StringToToken("é") })
+	_, err = parseTokenSafe("I")
+	assert.EqualError(t, err, "empty integer")
+}
+
+// The codec must agree with the evaluator's U$ and U#, which convert
+// between a string's characters and base 94 digits.
+func TestCodecProperties(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		n := new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(r.Intn(400))))
+		digits, err := EncodeInt(n)
+		assert.NoError(t, err)
+		back, err := DecodeInt(digits)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n.Cmp(back), "%s", n)
+		if n.Sign() > 0 {
+			assert.NotEqual(t, byte(firstDigit), digits[0], "leading zero in %q", digits)
+		}
+
+		b := make([]byte, r.Intn(200))
+		for j := range b {
+			b[j] = lookup[r.Intn(len(lookup))]
+		}
+		text := string(b)
+		body, err := EncodeString(text)
+		assert.NoError(t, err)
+		assert.Len(t, body, len(text))
+		decoded, err := DecodeString(body)
+		assert.NoError(t, err)
+		assert.Equal(t, text, decoded)
+
+		if n.Sign() > 0 {
+			str, err := new(Evaluator).Eval(Unop{"$", Integer{n}}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, String(mustDecodeString(t, digits)), str)
+			back, err := new(Evaluator).Eval(Unop{"#", str}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, n.Cmp(back.(Integer).Int))
+		}
+	}
+}
+
+func mustDecodeString(t *testing.T, s string) string {
+	ret, err := DecodeString(s)
+	assert.NoError(t, err)
+	return ret
+}
+
+func TestStringStreams(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewStringEncoder(&buf)
+	_, err := io.WriteString(w, "get ")
+	assert.NoError(t, err)
+	_, err = io.WriteString(w, "index")
+	assert.NoError(t, err)
+	assert.Equal(t, "'%4}).$%8", buf.String())
+	n, err := io.WriteString(w, "ok\té")
+	assert.EqualError(t, err, `unsupported character '\t' at offset 11`)
+	assert.Equal(t, 2, n)
+
+	text, err := io.ReadAll(NewStringDecoder(strings.NewReader("'%4}).$%8")))
+	assert.NoError(t, err)
+	assert.Equal(t, "get index", string(text))
+	_, err = io.ReadAll(NewStringDecoder(strings.NewReader("'% 4")))
+	assert.EqualError(t, err, `invalid string character ' ' at offset 2`)
+}
+
+func megabyteText() string {
+	b := make([]byte, 1<<20)
+	for i := range b {
+		b[i] = lookup[i%len(lookup)]
+	}
+	return string(b)
+}
+
+func BenchmarkEncodeString(b *testing.B) {
+	s := megabyteText()
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		EncodeString(s)
+	}
+}
+
+func BenchmarkDecodeString(b *testing.B) {
+	s, _ := EncodeString(megabyteText())
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		DecodeString(s)
+	}
+}
+
+func BenchmarkParseStringToken(b *testing.B) {
+	tok := string(StringToToken(megabyteText()))
+	b.SetBytes(int64(len(tok)))
+	for i := 0; i < b.N; i++ {
+		ParseToken(tok)
+	}
+}
+
+func BenchmarkStringEncoder(b *testing.B) {
+	s := []byte(megabyteText())
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		NewStringEncoder(io.Discard).Write(s)
+	}
+}
+
+func BenchmarkDecodeInt(b *testing.B) {
+	s, _ := EncodeString(megabyteText()[:1<<14])
+	b.SetBytes(int64(len(s)))
+	for i := 0; i < b.N; i++ {
+		DecodeInt(s)
+	}
+}
@@ -1,19 +1,206 @@
 package icfp
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
-func CommunicateToken(s string) ([]Expr, error) {
-	request, err := http.NewRequest("POST", "https://boundvariable.space/communicate", strings.NewReader(s))
+const DefaultBaseURL = "https://boundvariable.space"
+
+const DefaultTimeout = 60 * time.Second
+
+const DefaultRequestsPerMinute = 10
+
+// Client talks to the /communicate endpoint. The zero value is not usable
+// without a Token; see NewClient for loading one from the environment.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+	// Timeout bounds each request; zero means DefaultTimeout.
+	Timeout time.Duration
+	// Limiter throttles every request and Limiters additionally throttle
+	// requests by command ("get", "solve", "test", ...).
+	Limiter  *RateLimiter
+	Limiters map[string]*RateLimiter
+	// Retry controls retries of network errors, 429s and 5xxs. Network
+	// errors aren't retried for `solve`, which the server may have processed.
+	Retry RetryPolicy
+	// Cache, if set, serves problem inputs (see Cacheable).
+	Cache *Cache
+	// Budget bounds the evaluation of each reply.
+	Budget EvalBudget
+	// Ledger, if set, records solutions, and Solve only submits answers that
+	// beat the best one recorded.
+	Ledger *Ledger
+	// Queue, if set, keeps submissions until they get a reply.
+	Queue   *Queue
+	Metrics Metrics
+}
+
+type clientConfig struct {
+	URL               string             `json:"url"`
+	Token             string             `json:"token"`
+	RequestsPerMinute float64            `json:"requests_per_minute"`
+	Limits            map[string]float64 `json:"limits"`
+	CacheDir          string             `json:"cache_dir"`
+	Ledger            string             `json:"ledger"`
+	Queue             string             `json:"queue"`
+}
+
+// ConfigPath is where NewClient looks for a JSON config file: $ICFP_CONFIG,
+// or icfp2024/config.json in the user config directory. For example
+//
+//	{"url": "...", "token": "...", "requests_per_minute": 20, "limits": {"solve": 4}, "cache_dir": "...", "ledger": "...", "queue": "..."}
+//
+// where limits are per command, in requests per minute. A cache_dir (or
+// $ICFP_CACHE) of "off" disables the response cache, and likewise a ledger
+// path (or $ICFP_LEDGER) of "off" disables the solutions ledger and a queue
+// path (or $ICFP_QUEUE) of "off" the submission queue.
+func ConfigPath() string {
+	if p := os.Getenv("ICFP_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "icfp2024", "config.json")
+}
+
+// statePath is name in the directory of ConfigPath, so that the ledger and
+// queue are shared by every tool wherever it runs, and unlike the cache are
+// never cleared. It is name in the current directory if there is no user
+// config directory.
+func statePath(name string) string {
+	p := ConfigPath()
+	if p == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(p), name)
+}
+
+// NewClient builds a client from the config file, overridden by the
+// ICFP_URL and ICFP_TOKEN environment variables, with the default rate limit
+// and retry policy.
+func NewClient() (*Client, error) {
+	c := &Client{BaseURL: DefaultBaseURL, Limiter: NewRateLimiter(DefaultRequestsPerMinute, 3), Retry: DefaultRetryPolicy}
+	ledger, queue := "", ""
+	if p := ConfigPath(); p != "" {
+		byts, err := os.ReadFile(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			var cfg clientConfig
+			if err := json.Unmarshal(byts, &cfg); err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			if cfg.URL != "" {
+				c.BaseURL = cfg.URL
+			}
+			c.Token = cfg.Token
+			if cfg.RequestsPerMinute > 0 {
+				c.Limiter = NewRateLimiter(cfg.RequestsPerMinute, 3)
+			}
+			for cmd, perMinute := range cfg.Limits {
+				if c.Limiters == nil {
+					c.Limiters = map[string]*RateLimiter{}
+				}
+				c.Limiters[cmd] = NewRateLimiter(perMinute, 1)
+			}
+			if cfg.CacheDir != "" {
+				c.Cache = &Cache{Dir: cfg.CacheDir}
+			}
+			ledger, queue = cfg.Ledger, cfg.Queue
+		}
+	}
+	if u := os.Getenv("ICFP_URL"); u != "" {
+		c.BaseURL = u
+	}
+	if t := os.Getenv("ICFP_TOKEN"); t != "" {
+		c.Token = t
+	}
+	if c.Cache == nil || os.Getenv("ICFP_CACHE") != "" {
+		c.Cache = &Cache{Dir: DefaultCacheDir()}
+	}
+	if c.Cache.Dir == "off" || c.Cache.Dir == "" {
+		c.Cache = nil
+	}
+	if ledger == "" || os.Getenv("ICFP_LEDGER") != "" {
+		ledger = DefaultLedgerPath()
+	}
+	if ledger != "off" {
+		l, err := OpenLedger(ledger)
+		if err != nil {
+			return nil, err
+		}
+		c.Ledger = l
+	}
+	if queue == "" || os.Getenv("ICFP_QUEUE") != "" {
+		queue = DefaultQueuePath()
+	}
+	if queue != "off" {
+		q, err := OpenQueue(queue)
+		if err != nil {
+			return nil, err
+		}
+		c.Queue = q
+	}
+	return c, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// wait waits for the client's rate limiter and the one for verb, if any.
+func (c *Client) wait(ctx context.Context, verb string) error {
+	limiters := []*RateLimiter{c.Limiter}
+	if l, ok := c.Limiters[verb]; ok {
+		limiters = append(limiters, l)
+	}
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		waited, err := l.Wait(ctx)
+		c.Metrics.RateLimitWait.Add(int64(waited))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, s string) ([]byte, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(c.BaseURL, "/")+"/communicate", strings.NewReader(s))
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Add("Authorization", "Bearer "+"3b4c0eaa-bdc2-42ff-8e28-4a652814bd73")
-	resp, err := http.DefaultClient.Do(request)
+	request.Header.Add("Authorization", "Bearer "+c.Token)
+	c.Metrics.Requests.Add(1)
+	resp, err := c.httpClient().Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -22,25 +209,214 @@ func CommunicateToken(s string) ([]Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("%s\n\n", byts)
-	expr := Parse(string(byts))
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(byts)}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			statusErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return nil, statusErr
+	}
+	return byts, nil
+}
+
+// send posts req, the encoding of the command s. Network errors aren't
+// retried for `solve`: a request that timed out may still have been
+// processed, which would submit it twice.
+func (c *Client) send(ctx context.Context, s, req string) ([]byte, error) {
+	verb, _, _ := strings.Cut(s, " ")
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx, verb); err != nil {
+			return nil, err
+		}
+		byts, err := c.post(ctx, req)
+		if err == nil {
+			return byts, nil
+		}
+		var delay time.Duration
+		var statusErr *StatusError
+		switch {
+		case ctx.Err() != nil:
+			c.Metrics.Failures.Add(1)
+			return nil, err
+		case errors.As(err, &statusErr):
+			if !statusErr.Temporary() {
+				c.Metrics.Failures.Add(1)
+				return nil, err
+			}
+			delay = statusErr.RetryAfter
+		case verb == "solve":
+			c.Metrics.Failures.Add(1)
+			return nil, err
+		}
+		if attempt >= c.Retry.MaxRetries {
+			c.Metrics.Failures.Add(1)
+			return nil, err
+		}
+		if d := c.Retry.delay(attempt); d > delay {
+			delay = d
+		}
+		c.Metrics.Retries.Add(1)
+		Logger(ctx).Warn("retrying", "attempt", attempt+1, "delay", delay, "err", err)
+		c.Metrics.BackoffWait.Add(int64(delay))
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CommunicateToken sends the encoded request s as is and parses the reply.
+func (c *Client) CommunicateToken(ctx context.Context, s string) ([]Expr, error) {
+	if c.Token == "" {
+		return nil, fmt.Errorf("no token: set ICFP_TOKEN or add it to %s", ConfigPath())
+	}
+	byts, err := c.send(ctx, DecodeRequest(s), s)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := parseSafe(strings.TrimSpace(string(byts)))
+	if err != nil {
+		return nil, &ResponseError{Raw: string(byts), Err: err}
+	}
 	return expr, nil
 }
 
-func CommunicateString(s string) (string, error) {
-	tok := StringToToken(s)
-	ret, err := CommunicateToken(string(tok))
+func (c *Client) CommunicateString(ctx context.Context, s string) (string, error) {
+	resp, err := c.Communicate(ctx, s)
 	if err != nil {
 		return "", err
 	}
-	expr, rest := CombineToExpr(ret)
+	return resp.Text, nil
+}
+
+// VerifyEncoding checks that the ICFP program token evaluates to s.
+func VerifyEncoding(token, s string) error {
+	tokens, err := parseSafe(strings.TrimSpace(token))
+	if err != nil {
+		return err
+	}
+	expr, rest := combinePartial(tokens)
 	if len(rest) > 0 {
-		return "", fmt.Errorf("didn't use all input! %v", rest)
+		return fmt.Errorf("%d unused tokens", len(rest))
+	}
+	ev := &Evaluator{MaxSteps: 10000000}
+	res, err := ev.Eval(expr, nil)
+	if err != nil {
+		return err
+	}
+	if str, ok := res.(String); !ok || string(str) != s {
+		return fmt.Errorf("evaluates to %s", truncate(RenderAsLambda(res), 60))
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes, on a rune boundary so that renderings
+// with λ stay valid UTF-8.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// ShortestEncoding returns the shortest of the plain string token for s and
+// the candidates that evaluate to s, logging the size of each to ctx's
+// logger.
+func ShortestEncoding(ctx context.Context, s string, candidates ...string) string {
+	best := string(StringToToken(s))
+	if len(candidates) == 0 {
+		return best
+	}
+	l := Logger(ctx).With("command", truncate(s, 40))
+	l.Debug("plain string", "bytes", len(best))
+	for i, cand := range candidates {
+		if err := VerifyEncoding(cand, s); err != nil {
+			l.Warn("candidate rejected", "candidate", i, "bytes", len(cand), "err", err)
+			continue
+		}
+		l.Debug("candidate", "candidate", i, "bytes", len(cand))
+		if len(cand) < len(best) {
+			best = cand
+		}
+	}
+	l.Debug("shortest encoding", "bytes", len(best))
+	return best
+}
+
+// Communicate sends s as the shortest of its candidate encodings (see
+// ShortestEncoding) and evaluates the reply within the client's Budget.
+func (c *Client) Communicate(ctx context.Context, s string, candidates ...string) (*Response, error) {
+	if err := ValidateText(s); err != nil {
+		return nil, err
+	}
+	return c.communicate(ctx, s, func() string { return ShortestEncoding(ctx, s, candidates...) })
+}
+
+// communicate sends s as encoded by encode, which is only called if s isn't
+// served from the cache.
+func (c *Client) communicate(ctx context.Context, s string, encode func() string) (*Response, error) {
+	l := Logger(ctx).With("command", truncate(s, 40))
+	mode := cacheMode(ctx)
+	useCache := c.Cache != nil && Cacheable(s) && mode != CacheBypass
+	if useCache && mode == CacheDefault {
+		if entry, ok, err := c.Cache.Get(s); err == nil && ok {
+			c.Metrics.CacheHits.Add(1)
+			l.Debug("cache hit", "bytes", len(entry.Response))
+			return &Response{Text: entry.Response, Cached: true}, nil
+		}
 	}
-	res := Eval(expr, nil)
-	out, ok := res.(String)
-	if !ok {
-		return "", fmt.Errorf("expected string, got %T", res)
+	if c.Token == "" {
+		return nil, fmt.Errorf("no token: set ICFP_TOKEN or add it to %s", ConfigPath())
+	}
+	start := time.Now()
+	req := encode()
+	byts, err := c.send(ctx, s, req)
+	if err != nil {
+		l.Error("request failed", "sent", len(req), "duration", time.Since(start), "err", err)
+		return nil, err
+	}
+	resp, err := DecodeResponse(string(byts), c.Budget)
+	if err != nil {
+		l.Error("bad response", "sent", len(req), "received", len(byts), "duration", time.Since(start), "err", err)
+		return nil, err
+	}
+	l.Info("communicate", "sent", len(req), "received", len(byts), "bytes", len(resp.Text), "steps", resp.Stats.Steps, "duration", time.Since(start))
+	if useCache {
+		// The cache is best effort; a failed write just means refetching.
+		_ = c.Cache.Put(s, resp.Text)
+	}
+	return resp, nil
+}
+
+var (
+	defaultClient    *Client
+	defaultClientErr error
+	defaultOnce      sync.Once
+)
+
+// DefaultClient returns the client used by CommunicateToken and
+// CommunicateString, built once by NewClient.
+func DefaultClient() (*Client, error) {
+	defaultOnce.Do(func() {
+		defaultClient, defaultClientErr = NewClient()
+	})
+	return defaultClient, defaultClientErr
+}
+
+func CommunicateToken(s string) ([]Expr, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.CommunicateToken(context.Background(), s)
+}
+
+func CommunicateString(s string) (string, error) {
+	c, err := DefaultClient()
+	if err != nil {
+		return "", err
 	}
-	return string(out), nil
+	return c.CommunicateString(context.Background(), s)
 }
@@ -0,0 +1,233 @@
+package icfp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCommunicateString(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/communicate", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `S'%4}).$%8`, string(body))
+		io.WriteString(w, string(StringToToken("Hello and welcome")))
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL + "/", Token: "secret", HTTPClient: server.Client()}
+	s, err := c.CommunicateString(context.Background(), "get index")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello and welcome", s)
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL}
+	_, err := c.CommunicateString(context.Background(), "get index")
+	assert.ErrorContains(t, err, "no token")
+
+	c.Token = "wrong"
+	_, err = c.CommunicateString(context.Background(), "get index")
+	assert.EqualError(t, err, "401 Unauthorized: bad token\n")
+
+	c.Token = "secret"
+	c.Timeout = 10 * time.Millisecond
+	_, err = c.CommunicateString(context.Background(), "get index")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNewClient(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(config, []byte(`{"url": "http://localhost:8000", "token": "from-file", "limits": {"solve": 4}}`), 0600))
+	t.Setenv("ICFP_CONFIG", config)
+	t.Setenv("ICFP_URL", "")
+	t.Setenv("ICFP_TOKEN", "")
+	t.Setenv("ICFP_LEDGER", "")
+
+	c, err := NewClient()
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8000", c.BaseURL)
+	assert.Equal(t, "from-file", c.Token)
+	assert.Contains(t, c.Limiters, "solve")
+	// The ledger lives with the config, wherever the tool is run.
+	assert.Equal(t, filepath.Join(filepath.Dir(config), "solutions.json"), c.Ledger.Path)
+
+	t.Setenv("ICFP_TOKEN", "from-env")
+	c, err = NewClient()
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", c.Token)
+
+	t.Setenv("ICFP_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("ICFP_URL", "")
+	c, err = NewClient()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBaseURL, c.BaseURL)
+}
+
+func TestClientRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		case 2:
+			http.Error(w, "oops", http.StatusBadGateway)
+		default:
+			io.WriteString(w, string(StringToToken("ok")))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL, Token: "secret", Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}}
+	s, err := c.CommunicateString(context.Background(), "get index")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", s)
+	assert.Equal(t, int64(3), c.Metrics.Requests.Load())
+	assert.Equal(t, int64(2), c.Metrics.Retries.Load())
+
+	calls.Store(0)
+	c.Retry.MaxRetries = 1
+	_, err = c.CommunicateString(context.Background(), "get index")
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+	assert.Equal(t, int64(1), c.Metrics.Failures.Load())
+}
+
+func TestClientDoesNotRetrySolveAfterNetworkError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// Drop the connection, as if the reply was lost.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		io.WriteString(w, string(StringToToken("ok")))
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL, Token: "secret", Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}}
+	s, err := c.CommunicateString(context.Background(), "get index")
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", s)
+	assert.Equal(t, int32(2), calls.Load())
+
+	calls.Store(0)
+	_, err = c.CommunicateString(context.Background(), "solve lambdaman1 LLL")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int64(1), c.Metrics.Failures.Load())
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(60*100, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := l.Wait(context.Background())
+		assert.NoError(t, err)
+	}
+	// Two requests come from the burst, two more take 10ms each.
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+
+	l = NewRateLimiter(1, 1)
+	_, err := l.Wait(context.Background())
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = l.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClientPerCommandLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, string(StringToToken("ok")))
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL, Token: "secret", Limiters: map[string]*RateLimiter{"solve": NewRateLimiter(1, 1)}}
+	_, err := c.CommunicateString(context.Background(), "solve lambdaman1 L")
+	assert.NoError(t, err)
+	_, err = c.CommunicateString(context.Background(), "get lambdaman1")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.CommunicateString(ctx, "solve lambdaman1 L")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A solve sent as a shorter program is limited the same way.
+	doubled, err := ParseLambda(`(. "solve lambdaman1 " ((λd.(d (d (d (d (d (d "R"))))))) (λx.(. x x))))`)
+	assert.NoError(t, err)
+	short := Encode(doubled)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.Solve(ctx, "lambdaman1", strings.Repeat("R", 64), short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = c.CommunicateToken(ctx, short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "(λa...", truncate("(λa.a)", 4))
+	assert.Equal(t, "(...", truncate("(λa.a)", 2))
+	assert.Equal(t, "(λa.a)", truncate("(λa.a)", 7))
+}
+
+func TestShortestEncoding(t *testing.T) {
+	s := "solve lambdaman1 " + strings.Repeat("R", 64)
+	doubled, err := ParseLambda(`(. "solve lambdaman1 " ((λd.(d (d (d (d (d (d "R"))))))) (λx.(. x x))))`)
+	assert.NoError(t, err)
+	short := Encode(doubled)
+	assert.NoError(t, VerifyEncoding(short, s))
+	assert.EqualError(t, VerifyEncoding(`S%#(/`, s), `evaluates to "echo"`)
+	assert.EqualError(t, VerifyEncoding(`I! I!`, s), `1 unused tokens`)
+
+	plain := string(StringToToken(s))
+	assert.Less(t, len(short), len(plain))
+	assert.Equal(t, plain, ShortestEncoding(context.Background(), s))
+	assert.Equal(t, short, ShortestEncoding(context.Background(), s, `S%#(/`, short, plain+" "))
+
+	// Rejected candidates are logged with the caller's fields.
+	var buf bytes.Buffer
+	h, _ := NewLogHandler(&buf, "json", "info")
+	ctx := WithLogger(context.Background(), slog.New(h).With("problem", "lambdaman1"))
+	assert.Equal(t, plain, ShortestEncoding(ctx, s, `S%#(/`))
+	records := logRecords(t, &buf)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "candidate rejected", records[0]["msg"])
+	assert.Equal(t, "lambdaman1", records[0]["problem"])
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		io.WriteString(w, string(StringToToken("Correct, you solved lambdaman1 with a score of 40!")))
+	}))
+	defer server.Close()
+	c := &Client{BaseURL: server.URL, Token: "secret", HTTPClient: server.Client()}
+	res, err := c.Solve(context.Background(), "lambdaman1", strings.Repeat("R", 64), short)
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.Equal(t, short, got)
+}
@@ -0,0 +1,124 @@
+package icfp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type DotOptions struct {
+	// Share draws structurally identical subterms once, with an edge from each
+	// use.
+	Share bool
+	// CollapseLambdas draws chains of lambdas like λx.λy.λz. as one node.
+	CollapseLambdas bool
+	// MaxLabel truncates long string literals; zero means 40.
+	MaxLabel int
+}
+
+type dotEdge struct {
+	from, to int
+	label    string
+}
+
+type dotWriter struct {
+	opts   DotOptions
+	labels []string
+	uses   []int
+	edges  []dotEdge
+	keys   map[string]int
+}
+
+func (d *dotWriter) node(label string, children []int, edgeLabels []string) int {
+	var key string
+	if d.opts.Share {
+		parts := []string{label}
+		for _, c := range children {
+			parts = append(parts, strconv.Itoa(c))
+		}
+		key = strings.Join(parts, "\x00")
+		if id, ok := d.keys[key]; ok {
+			d.uses[id]++
+			return id
+		}
+	}
+	id := len(d.labels)
+	d.labels = append(d.labels, label)
+	d.uses = append(d.uses, 1)
+	for i, c := range children {
+		d.edges = append(d.edges, dotEdge{id, c, edgeLabels[i]})
+	}
+	if d.opts.Share {
+		d.keys[key] = id
+	}
+	return id
+}
+
+func (d *dotWriter) add(e Expr) int {
+	switch v := e.(type) {
+	case Integer, Boolean:
+		return d.node(RenderAsLambda(v), nil, nil)
+	case String:
+		s := RenderAsLambda(v)
+		max := d.opts.MaxLabel
+		if max == 0 {
+			max = 40
+		}
+		return d.node(truncate(s, max), nil, nil)
+	case Var:
+		return d.node(RenderAsLambda(v), nil, nil)
+	case If:
+		return d.node("if", []int{d.add(v.Test), d.add(v.Then), d.add(v.Else)}, []string{"test", "then", "else"})
+	case Binop:
+		if isApply(v.Op) {
+			return d.node(v.Op, []int{d.add(v.Left), d.add(v.Right)}, []string{"fn", "arg"})
+		}
+		return d.node(v.Op, []int{d.add(v.Left), d.add(v.Right)}, []string{"", ""})
+	case Unop:
+		return d.node(v.Op, []int{d.add(v.Arg)}, []string{""})
+	case Lambda:
+		label := "λ" + VarName(v.Param)
+		body := v.Body
+		if d.opts.CollapseLambdas {
+			for {
+				inner, ok := body.(Lambda)
+				if !ok {
+					break
+				}
+				label += " " + VarName(inner.Param)
+				body = inner.Body
+			}
+		}
+		return d.node(label, []int{d.add(body)}, []string{""})
+	default:
+		panic(fmt.Sprintf("Unknown type: %T", e))
+	}
+}
+
+// WriteDot writes e as a Graphviz digraph. Shared nodes (used more than once
+// with DotOptions.Share) are drawn filled.
+func WriteDot(w io.Writer, e Expr, opts DotOptions) error {
+	d := &dotWriter{opts: opts, keys: map[string]int{}}
+	d.add(e)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph expr {\n")
+	fmt.Fprintf(bw, "  node [shape=box fontname=\"monospace\"];\n")
+	for id, label := range d.labels {
+		if d.uses[id] > 1 {
+			fmt.Fprintf(bw, "  n%d [label=%s style=filled fillcolor=lightgrey];\n", id, strconv.Quote(label))
+		} else {
+			fmt.Fprintf(bw, "  n%d [label=%s];\n", id, strconv.Quote(label))
+		}
+	}
+	for _, edge := range d.edges {
+		if edge.label == "" {
+			fmt.Fprintf(bw, "  n%d -> n%d;\n", edge.from, edge.to)
+		} else {
+			fmt.Fprintf(bw, "  n%d -> n%d [label=%s];\n", edge.from, edge.to, strconv.Quote(edge.label))
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
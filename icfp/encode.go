@@ -0,0 +1,64 @@
+package icfp
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// encodeIndex encodes a variable number, which is never negative.
+func encodeIndex(i int64) string {
+	s, _ := EncodeInt(big.NewInt(i))
+	return s
+}
+
+func encodeTo(b *strings.Builder, e Expr) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	switch v := e.(type) {
+	case Boolean:
+		if v {
+			b.WriteString("T")
+		} else {
+			b.WriteString("F")
+		}
+	case Integer:
+		if v.Sign() < 0 {
+			b.WriteString("U-")
+			encodeTo(b, Integer{new(big.Int).Neg(v.Int)})
+			return
+		}
+		digits, _ := EncodeInt(v.Int)
+		b.WriteString("I" + digits)
+	case String:
+		b.WriteString(string(StringToToken(string(v))))
+	case If:
+		b.WriteString("?")
+		encodeTo(b, v.Test)
+		encodeTo(b, v.Then)
+		encodeTo(b, v.Else)
+	case Binop:
+		b.WriteString("B" + v.Op)
+		encodeTo(b, v.Left)
+		encodeTo(b, v.Right)
+	case Unop:
+		b.WriteString("U" + v.Op)
+		encodeTo(b, v.Arg)
+	case Lambda:
+		b.WriteString("L" + encodeIndex(v.Param))
+		encodeTo(b, v.Body)
+	case Var:
+		b.WriteString("v" + encodeIndex(v.v))
+	default:
+		panic(fmt.Sprintf("Unknown type: %T", e))
+	}
+}
+
+// Encode renders e as space separated ICFP tokens, the inverse of Parse and
+// CombineToExpr.
+func Encode(e Expr) string {
+	var b strings.Builder
+	encodeTo(&b, e)
+	return b.String()
+}
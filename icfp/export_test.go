@@ -0,0 +1,67 @@
+package icfp
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	s := `B+ I7c B* B$ B$ L" B$ L# B$ v" B$ v# v# L# B$ v" B$ v# v# L$ L% ? B= v% I! I" B+ I" B$ v$ B- v% I" I":c1+0 I!`
+	expr, _ := CombineToExpr(Parse(s))
+	byts, err := MarshalExpr(expr)
+	assert.NoError(t, err)
+	parsed, err := UnmarshalExpr(byts)
+	assert.NoError(t, err)
+	assert.Equal(t, expr, parsed)
+	assert.Equal(t, s, Encode(parsed))
+}
+
+func TestUnmarshalExpr(t *testing.T) {
+	expr, err := UnmarshalExpr([]byte(`{"type":"unop","op":"$","arg":{"type":"int","value":"123456789012345678901234567890"}}`))
+	assert.NoError(t, err)
+	i, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.Equal(t, Unop{"$", Integer{i}}, expr)
+
+	_, err = UnmarshalExpr([]byte(`{"type":"binop","op":"+","left":{"type":"int","value":1}}`))
+	assert.EqualError(t, err, "$.right: missing")
+	_, err = UnmarshalExpr([]byte(`{"type":"lambda","body":{"type":"var","var":0}}`))
+	assert.EqualError(t, err, "$: lambda needs a non-negative param")
+	_, err = UnmarshalExpr([]byte(`{"type":"if","test":{"type":"bool","value":"yes"}}`))
+	assert.EqualError(t, err, `$.test: bad bool value "yes"`)
+}
+
+func TestWriteDot(t *testing.T) {
+	expr, err := ParseLambda(`(λx y.(+ (* x x) (* x x)))`)
+	assert.NoError(t, err)
+
+	var b bytes.Buffer
+	assert.NoError(t, WriteDot(&b, expr, DotOptions{Share: true, CollapseLambdas: true}))
+	assert.Equal(t, `digraph expr {
+  node [shape=box fontname="monospace"];
+  n0 [label="x" style=filled fillcolor=lightgrey];
+  n1 [label="*" style=filled fillcolor=lightgrey];
+  n2 [label="+"];
+  n3 [label="λx y"];
+  n1 -> n0;
+  n1 -> n0;
+  n2 -> n1;
+  n2 -> n1;
+  n3 -> n2;
+}
+`, b.String())
+
+	b.Reset()
+	assert.NoError(t, WriteDot(&b, expr, DotOptions{}))
+	assert.Contains(t, b.String(), `n8 [label="λx"];`)
+	assert.Contains(t, b.String(), `n7 [label="λy"];`)
+
+	// Long strings are cut short without splitting a character.
+	b.Reset()
+	assert.NoError(t, WriteDot(&b, String("aλλλ"), DotOptions{MaxLabel: 5}))
+	assert.Contains(t, b.String(), `n0 [label="\"aλ..."];`)
+	assert.True(t, utf8.Valid(b.Bytes()))
+}
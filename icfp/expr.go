@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/big"
 	"strings"
+	"time"
 )
 
 type Expr interface {
@@ -33,6 +34,8 @@ type Lambda struct {
 	Param int64
 	Body  Expr
 	Env   Env
+	// Pos identifies the lambda in profiles; see Profile.
+	Pos int
 }
 type Var struct {
 	v int64
@@ -49,11 +52,12 @@ func (v Var) IsExpr()     {}
 
 const lookup = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!\"#$%&'()*+,-./:;<=>?@[\\]^_`|~ \n"
 
+// ParseInteger is DecodeInt for tokens, panicking like ParseToken on bad
+// input.
 func ParseInteger(s string) *big.Int {
-	ret := big.NewInt(0)
-	for i := 0; i < len(s); i++ {
-		ret = ret.Mul(ret, big.NewInt(94))
-		ret = ret.Add(ret, big.NewInt(int64(s[i])-33))
+	ret, err := DecodeInt(s)
+	if err != nil {
+		panic(err.Error())
 	}
 	return ret
 }
@@ -68,9 +72,9 @@ func ParseToken(token string) Expr {
 	case 'I':
 		return Integer{ParseInteger(token[1:])}
 	case 'S':
-		s := ""
-		for i := 1; i < len(token); i++ {
-			s += string(lookup[int(token[i])-33])
+		s, err := DecodeString(token[1:])
+		if err != nil {
+			panic(err.Error())
 		}
 		return String(s)
 	case '?':
@@ -136,6 +140,9 @@ type Thunk struct {
 	Env       Env
 	Value     Expr
 	Evaluated bool
+	ByName    bool
+	// frame is where the thunk was created, when profiling.
+	frame *profNode
 }
 
 type Env map[int64]*Thunk
@@ -148,45 +155,253 @@ func copyEnv(e Env) Env {
 	return newEnv
 }
 
+type Strategy int
+
+const (
+	CallByNeed Strategy = iota
+	CallByName
+	CallByValue
+)
+
+var strategyNames = []string{"need", "name", "value"}
+
+func (s Strategy) String() string {
+	if int(s) < len(strategyNames) {
+		return strategyNames[s]
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+func ParseStrategy(s string) (Strategy, error) {
+	for i, name := range strategyNames {
+		if s == name {
+			return Strategy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown strategy %q (want need, name or value)", s)
+}
+
+type Stats struct {
+	Steps          int64
+	BetaReductions int64
+	MaxDepth       int
+	// Memory estimates the bytes of environments and thunks allocated.
+	Memory int64
+}
+
+type BudgetError struct {
+	Budget string
+	Limit  int64
+}
+
+// Error reports the limit in the budget's unit: nanoseconds for "time",
+// bytes for "memory".
+func (e *BudgetError) Error() string {
+	if e.Budget == "time" {
+		return fmt.Sprintf("exceeded time budget of %s", time.Duration(e.Limit))
+	}
+	return fmt.Sprintf("exceeded %s budget of %d", e.Budget, e.Limit)
+}
+
+// Evaluator evaluates expressions with a strategy for `$` and optional step,
+// recursion depth, time, value size and memory budgets (zero means
+// unlimited). `~` is always call-by-need and `!` always call-by-value.
+type Evaluator struct {
+	Strategy Strategy
+	MaxSteps int64
+	MaxDepth int
+	// Timeout bounds the wall-clock time of each call to Eval.
+	Timeout time.Duration
+	// MaxValueBytes bounds the size of each string and integer computed.
+	MaxValueBytes int64
+	// MaxMemory bounds Stats.Memory, the environments and thunks that
+	// evaluation allocates. Each step can copy a large environment, so the
+	// step budget alone doesn't bound these.
+	MaxMemory int64
+	Stats     Stats
+	Tracer    Tracer
+	// Profile, if set, collects where the work of each evaluation is done.
+	Profile  *Profile
+	depth    int
+	deadline time.Time
+	frame    *profNode
+}
+
+// Eval evaluates expr, turning budget overruns and runtime failures into
+// errors.
+func (ev *Evaluator) Eval(expr Expr, env Env) (ret Expr, err error) {
+	ev.depth = 0
+	ev.deadline = time.Time{}
+	if ev.Timeout > 0 {
+		ev.deadline = time.Now().Add(ev.Timeout)
+	}
+	ev.frame = nil
+	if ev.Profile != nil {
+		expr, _ = annotateLambdas(expr, 1)
+		ev.frame = ev.Profile.root
+		start := time.Now()
+		defer func() { ev.Profile.Duration += time.Since(start) }()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	return ev.eval(expr, env), nil
+}
+
 func Eval(expr Expr, env Env) Expr {
-	fmt.Printf(".")
+	ev := &Evaluator{}
+	return ev.eval(expr, env)
+}
+
+func (ev *Evaluator) eval(expr Expr, env Env) Expr {
+	ev.Stats.Steps++
+	if ev.frame != nil {
+		ev.frame.self.Steps++
+	}
+	if ev.MaxSteps > 0 && ev.Stats.Steps > ev.MaxSteps {
+		panic(&BudgetError{Budget: "step", Limit: ev.MaxSteps})
+	}
+	ev.depth++
+	if ev.depth > ev.Stats.MaxDepth {
+		ev.Stats.MaxDepth = ev.depth
+		if ev.MaxDepth > 0 && ev.depth > ev.MaxDepth {
+			panic(&BudgetError{Budget: "depth", Limit: int64(ev.MaxDepth)})
+		}
+	}
+	// Checking the clock is comparatively slow, so only do it now and then.
+	if !ev.deadline.IsZero() && ev.Stats.Steps%1024 == 0 && time.Now().After(ev.deadline) {
+		panic(&BudgetError{Budget: "time", Limit: int64(ev.Timeout)})
+	}
+	ret := ev.step(expr, env)
+	if ev.MaxValueBytes > 0 && valueBytes(ret) > ev.MaxValueBytes {
+		panic(&BudgetError{Budget: "memory", Limit: ev.MaxValueBytes})
+	}
+	ev.depth--
+	return ret
+}
+
+// allocatingBinops are the operators that make a new integer or string.
+var allocatingBinops = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true, ".": true}
+
+func (ev *Evaluator) alloc() {
+	if ev.frame != nil {
+		ev.frame.self.Allocs++
+	}
+}
+
+// Estimated sizes of an environment entry and a thunk, for MaxMemory.
+const (
+	envEntryBytes = 16
+	thunkBytes    = 64
+)
+
+func (ev *Evaluator) charge(bytes int64) {
+	ev.Stats.Memory += bytes
+	if ev.MaxMemory > 0 && ev.Stats.Memory > ev.MaxMemory {
+		panic(&BudgetError{Budget: "memory", Limit: ev.MaxMemory})
+	}
+}
+
+func valueBytes(e Expr) int64 {
+	switch v := e.(type) {
+	case String:
+		return int64(len(v))
+	case Integer:
+		if v.Int != nil {
+			return int64(v.BitLen()+7) / 8
+		}
+	}
+	return 0
+}
+
+func (ev *Evaluator) step(expr Expr, env Env) Expr {
 	switch v := expr.(type) {
 	case Integer, Boolean, String:
 		return v
 	case Lambda:
-		return Lambda{Param: v.Param, Body: v.Body, Env: copyEnv(env)}
+		ev.alloc()
+		ev.charge(int64(len(env)) * envEntryBytes)
+		return Lambda{Param: v.Param, Body: v.Body, Env: copyEnv(env), Pos: v.Pos}
 	case Var:
 		thunk, ok := env[v.v]
 		if !ok {
 			panic(fmt.Sprintf("Unknown variable: %d", v.v))
 		}
-		if !thunk.Evaluated {
-			// fmt.Printf("Evaluating thunk: %s with env %v\n", RenderAsLambda(thunk.Expr), thunk.Env)
-			thunk.Value = Eval(thunk.Expr, thunk.Env)
+		if thunk.Evaluated {
+			return thunk.Value
+		}
+		// fmt.Printf("Evaluating thunk: %s with env %v\n", RenderAsLambda(thunk.Expr), thunk.Env)
+		frame := ev.frame
+		if thunk.frame != nil {
+			ev.frame = thunk.frame
+		}
+		value := ev.eval(thunk.Expr, thunk.Env)
+		ev.frame = frame
+		if !thunk.ByName {
+			thunk.Value = value
 			thunk.Evaluated = true
 		}
-		return thunk.Value
+		return value
 	case Binop:
 		var left, right Expr
-		if v.Op != "$" {
-			left = Eval(v.Left, env)
-			right = Eval(v.Right, env)
+		if !isApply(v.Op) {
+			left = ev.eval(v.Left, env)
+			right = ev.eval(v.Right, env)
+			if ev.Tracer != nil {
+				ev.Tracer.Trace(Event{Kind: EventPrimitive, Op: v.Op, Expr: v, Env: env, Depth: ev.depth, Args: []Expr{left, right}})
+			}
+			if allocatingBinops[v.Op] {
+				ev.alloc()
+			}
 		}
 		switch v.Op {
-		case "$":
+		case "$", "~", "!":
 			// fmt.Printf("Beta-reducing: %s with env %v\n", RenderAsLambda(v), env)
-			lambda := Eval(v.Left, env).(Lambda)
+			lambda := ev.eval(v.Left, env).(Lambda)
+			strategy := ev.Strategy
+			if v.Op == "~" {
+				strategy = CallByNeed
+			} else if v.Op == "!" {
+				strategy = CallByValue
+			}
 			// fmt.Printf("Creating thunk for arg: %s with env %v\n", RenderAsLambda(v.Right), env)
 			argThunk := &Thunk{
 				Expr:      v.Right,
 				Env:       env,
 				Value:     nil,
 				Evaluated: false,
+				ByName:    strategy == CallByName,
+				frame:     ev.frame,
+			}
+			if strategy == CallByValue {
+				argThunk.Value = ev.eval(v.Right, env)
+				argThunk.Evaluated = true
+			}
+			ev.Stats.BetaReductions++
+			ev.charge(thunkBytes + int64(len(lambda.Env)+1)*envEntryBytes)
+			if ev.Tracer != nil {
+				ev.Tracer.Trace(Event{Kind: EventBeta, Op: v.Op, Expr: v, Env: env, Depth: ev.depth, Lambda: lambda, Arg: argThunk})
 			}
 			newEnv := copyEnv(lambda.Env)
 			newEnv[lambda.Param] = argThunk
 			// fmt.Printf("Calling lambda: %s with env %v\n", RenderAsLambda(lambda.Body), newEnv)
-			return Eval(lambda.Body, newEnv)
+			if ev.frame == nil {
+				return ev.eval(lambda.Body, newEnv)
+			}
+			frame := ev.frame
+			ev.frame = frame.push(ev.Profile.fn(lambda))
+			ev.frame.self.Betas++
+			// The argument's thunk and the new environment.
+			ev.frame.self.Allocs += 2
+			ret := ev.eval(lambda.Body, newEnv)
+			ev.frame = frame
+			return ret
 		case "=":
 			i, oki := left.(Integer)
 			j, okj := right.(Integer)
@@ -232,7 +447,13 @@ func Eval(expr Expr, env Env) Expr {
 			panic(fmt.Sprintf("Unknown binop: %s", v.Op))
 		}
 	case Unop:
-		arg := Eval(v.Arg, env)
+		arg := ev.eval(v.Arg, env)
+		if ev.Tracer != nil {
+			ev.Tracer.Trace(Event{Kind: EventPrimitive, Op: v.Op, Expr: v, Env: env, Depth: ev.depth, Args: []Expr{arg}})
+		}
+		if v.Op != "!" {
+			ev.alloc()
+		}
 		switch v.Op {
 		case "-":
 			z := big.NewInt(0).Neg(arg.(Integer).Int)
@@ -241,48 +462,60 @@ func Eval(expr Expr, env Env) Expr {
 			return Boolean(!arg.(Boolean))
 		case "$":
 			i := arg.(Integer)
-			s := ""
-			for i.Cmp(big.NewInt(0)) != 0 {
-				d := big.NewInt(0).Mod(i.Int, big.NewInt(94))
-				i = Integer{Int: big.NewInt(0).Div(i.Int, big.NewInt(94))}
-				s = string(lookup[d.Int64()]) + s
+			if i.Sign() == 0 {
+				return String("")
+			}
+			digits, err := EncodeInt(i.Int)
+			if err != nil {
+				panic(err.Error())
 			}
+			s, _ := DecodeString(digits)
 			return String(s)
 		case "#":
 			s := arg.(String)
-			i := big.NewInt(0)
-			for _, c := range s {
-				i.Mul(i, big.NewInt(94))
-				i.Add(i, big.NewInt(int64(strings.Index(lookup, string(c)))))
+			if s == "" {
+				return Integer{Int: big.NewInt(0)}
+			}
+			digits, err := EncodeString(string(s))
+			if err != nil {
+				panic(err.Error())
 			}
+			i, _ := DecodeInt(digits)
 			return Integer{Int: i}
 		default:
 			panic(fmt.Sprintf("Unknown unop: %s", v.Op))
 		}
 	case If:
-		test := Eval(v.Test, env).(Boolean)
+		test := ev.eval(v.Test, env).(Boolean)
+		if ev.Tracer != nil {
+			ev.Tracer.Trace(Event{Kind: EventPrimitive, Op: "?", Expr: v, Env: env, Depth: ev.depth, Args: []Expr{test}})
+		}
 		if test {
-			return Eval(v.Then, env)
+			return ev.eval(v.Then, env)
 		} else {
-			return Eval(v.Else, env)
+			return ev.eval(v.Else, env)
 		}
 	default:
 		panic(fmt.Sprintf("Unknown type: %T", expr))
 	}
 }
 
+// StringToToken is EncodeString for text known to be valid; it panics on
+// characters that strings can't hold.
 func StringToToken(s string) String {
-	var ret String = "S"
-	for _, c := range s {
-		ret += String(byte(strings.Index(lookup, string(c)) + 33))
+	body, err := EncodeString(s)
+	if err != nil {
+		panic(err.Error())
 	}
-	return ret
+	return String("S" + body)
 }
 
-func RenderAsLambda(e Expr) string {
-	varLookup := []string{"x", "y", "z", "w", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
+var varLookup = []string{"x", "y", "z", "w", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
 
+func RenderAsLambda(e Expr) string {
 	switch v := e.(type) {
+	case nil:
+		return "_"
 	case Integer:
 		return fmt.Sprintf("%d", v)
 	case Boolean:
@@ -300,14 +533,14 @@ func RenderAsLambda(e Expr) string {
 		return fmt.Sprintf("(%s %s)", v.Op, RenderAsLambda(v.Arg))
 	case Lambda:
 		var s string
-		if v.Param >= int64(len(varLookup)) {
+		if v.Param < 0 || v.Param >= int64(len(varLookup)) {
 			s = fmt.Sprintf("v%d", v.Param)
 		} else {
 			s = varLookup[v.Param]
 		}
 		return fmt.Sprintf("(λ%s.%s)", s, RenderAsLambda(v.Body))
 	case Var:
-		if v.v >= int64(len(varLookup)) {
+		if v.v < 0 || v.v >= int64(len(varLookup)) {
 			return fmt.Sprintf("v%d", v.v)
 		}
 		return varLookup[v.v]
@@ -25,3 +25,36 @@ func TestEfficiency2(t *testing.T) {
 	v := evalString(t, s)
 	assert.Equal(t, Integer{Int: big.NewInt(17592186044416)}, v)
 }
+
+func TestEvaluatorStrategies(t *testing.T) {
+	// ((λx.(+ x x)) (+ 1 2)) evaluates the argument once by need, twice by name.
+	expr, _ := CombineToExpr(Parse(`B$ L# B+ v# v# B+ I" I#`))
+	steps := map[Strategy]int64{}
+	for _, s := range []Strategy{CallByNeed, CallByName, CallByValue} {
+		ev := &Evaluator{Strategy: s}
+		v, err := ev.Eval(expr, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, Integer{Int: big.NewInt(6)}, v)
+		assert.Equal(t, int64(1), ev.Stats.BetaReductions)
+		steps[s] = ev.Stats.Steps
+	}
+	assert.Equal(t, steps[CallByNeed], steps[CallByValue])
+	assert.Equal(t, steps[CallByNeed]+3, steps[CallByName])
+}
+
+func TestEvaluatorBudgets(t *testing.T) {
+	// Y (λf.λn. f n) 1 never terminates.
+	expr, _ := CombineToExpr(Parse(`B$ B$ L" B$ L# B$ v" B$ v# v# L# B$ v" B$ v# v# L$ L% B$ v$ v% I"`))
+	ev := &Evaluator{MaxSteps: 1000}
+	_, err := ev.Eval(expr, nil)
+	assert.Equal(t, &BudgetError{Budget: "step", Limit: 1000}, err)
+
+	ev = &Evaluator{MaxDepth: 50}
+	_, err = ev.Eval(expr, nil)
+	assert.Equal(t, &BudgetError{Budget: "depth", Limit: 50}, err)
+
+	ev = &Evaluator{}
+	expr, _ = CombineToExpr(Parse(`B+ I" S#`))
+	_, err = ev.Eval(expr, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package fakeserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const maxLambdamanMoves = 1000000
+
+const maxSpaceshipMoves = 10000000
+
+// simulateLambdaman walks lambdaman through grid and returns how many pills
+// are left uneaten.
+func simulateLambdaman(grid, moves string) (int, error) {
+	if len(moves) > maxLambdamanMoves {
+		return 0, fmt.Errorf("more than %d moves", maxLambdamanMoves)
+	}
+	rows := strings.Split(strings.TrimRight(grid, "\n"), "\n")
+	pills := map[pos]bool{}
+	var at pos
+	found := false
+	for y, row := range rows {
+		for x, c := range []byte(row) {
+			switch c {
+			case '.':
+				pills[pos{x, y}] = true
+			case 'L':
+				at, found = pos{x, y}, true
+			}
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no lambdaman in grid")
+	}
+	open := func(p pos) bool {
+		return p.y >= 0 && p.y < len(rows) && p.x >= 0 && p.x < len(rows[p.y]) && rows[p.y][p.x] != '#'
+	}
+	for i, m := range []byte(moves) {
+		next := at
+		switch m {
+		case 'U':
+			next.y--
+		case 'D':
+			next.y++
+		case 'L':
+			next.x--
+		case 'R':
+			next.x++
+		default:
+			return 0, fmt.Errorf("invalid move %q at %d", m, i)
+		}
+		if open(next) {
+			at = next
+			delete(pills, at)
+		}
+	}
+	return len(pills), nil
+}
+
+func parsePoints(s string) (map[pos]bool, error) {
+	points := map[pos]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		if line == "" {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+		x, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		points[pos{x, y}] = true
+	}
+	return points, nil
+}
+
+// simulateSpaceship flies the ship from the origin and returns how many of
+// the points were never visited. Moves are keypad digits: 7 8 9 accelerate
+// up-left, up and up-right, 1 2 3 the same downwards, and 5 coasts.
+func simulateSpaceship(points, moves string) (int, error) {
+	if len(moves) > maxSpaceshipMoves {
+		return 0, fmt.Errorf("more than %d moves", maxSpaceshipMoves)
+	}
+	left, err := parsePoints(points)
+	if err != nil {
+		return 0, err
+	}
+	var at, vel pos
+	for i, m := range []byte(moves) {
+		if m < '1' || m > '9' {
+			return 0, fmt.Errorf("invalid move %q at %d", m, i)
+		}
+		d := int(m - '1')
+		vel.x += d%3 - 1
+		vel.y += d/3 - 1
+		at.x += vel.x
+		at.y += vel.y
+		delete(left, at)
+	}
+	return len(left), nil
+}
@@ -0,0 +1,187 @@
+// Package fakeserver is a local stand-in for the contest's /communicate
+// endpoint, for tests (wrap a Server in httptest.NewServer) and offline runs.
+package fakeserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lukehoban/icfp2024/icfp"
+)
+
+// The contest runs `test 3d` for at most TestTickLimit ticks, and 3d
+// solutions for at most SolveTickLimit.
+const (
+	TestTickLimit  = 4
+	SolveTickLimit = 1000000
+)
+
+// Server answers ICFP-encoded requests. Pages for `get` are read from
+// Fixtures as <page>.txt, which also provides the lambdaman and spaceship
+// problems that `solve` checks solutions against.
+type Server struct {
+	Token    string
+	Fixtures string
+	// TickLimit bounds `test 3d` runs; zero means TestTickLimit.
+	TickLimit int
+	// MaxSteps bounds evaluation of each request; zero means 10,000,000.
+	MaxSteps int64
+}
+
+func New(token, fixtures string) *Server {
+	return &Server{Token: token, Fixtures: fixtures}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/communicate" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+		return
+	}
+	byts, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request, err := s.decode(string(byts))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reply, err := icfp.EncodeString(s.handle(request, len(byts)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	io.WriteString(w, "S"+reply)
+}
+
+func (s *Server) decode(body string) (string, error) {
+	maxSteps := s.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = 10000000
+	}
+	// Requests are evaluated with the same budget checks as replies.
+	resp, err := icfp.DecodeResponse(body, icfp.EvalBudget{MaxSteps: maxSteps})
+	if err != nil {
+		return "", fmt.Errorf("invalid request: %w", err)
+	}
+	return resp.Text, nil
+}
+
+// handle answers a decoded request; size is the length of the encoded
+// request, which is the score of a solution.
+func (s *Server) handle(request string, size int) string {
+	verb, args, _ := strings.Cut(request, " ")
+	switch verb {
+	case "get":
+		page, err := s.fixture(args)
+		if err != nil {
+			return err.Error()
+		}
+		return page
+	case "echo":
+		return args + "\n\nYou scored some points for using the echo service!"
+	case "solve":
+		problem, solution, _ := strings.Cut(args, " ")
+		return s.solve(problem, solution, size)
+	case "test":
+		course, rest, _ := strings.Cut(args, " ")
+		if course != "3d" {
+			return fmt.Sprintf("Unknown test course %s", course)
+		}
+		return s.test3D(rest)
+	}
+	return fmt.Sprintf("Unknown command %s", verb)
+}
+
+func (s *Server) fixture(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/\\.") {
+		return "", fmt.Errorf("Unknown page %s", name)
+	}
+	byts, err := os.ReadFile(filepath.Join(s.Fixtures, name+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("Unknown page %s", name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(byts), nil
+}
+
+func (s *Server) solve(problem, solution string, size int) string {
+	var simulate func(string, string) (int, error)
+	var what string
+	switch {
+	case strings.HasPrefix(problem, "lambdaman"):
+		simulate, what = simulateLambdaman, "pills were not eaten"
+	case strings.HasPrefix(problem, "spaceship"):
+		simulate, what = simulateSpaceship, "squares were not visited"
+	default:
+		return fmt.Sprintf("Unknown problem %s", problem)
+	}
+	input, err := s.fixture(problem)
+	if err != nil {
+		return fmt.Sprintf("Unknown problem %s", problem)
+	}
+	left, err := simulate(input, solution)
+	if err != nil {
+		return fmt.Sprintf("Your solution for %s is invalid: %v", problem, err)
+	}
+	if left > 0 {
+		return fmt.Sprintf("Your solution for %s is incorrect: %d %s", problem, left, what)
+	}
+	if strings.HasPrefix(problem, "spaceship") {
+		// Spaceship is scored by the number of moves, not the request.
+		size = len(solution)
+	}
+	return fmt.Sprintf("Correct, you solved %s with a score of %d!", problem, size)
+}
+
+// test3D runs `test 3d A B\n<program>`, replying with the trace of boards
+// followed by the submitted value or error and the spacetime volume.
+func (s *Server) test3D(args string) string {
+	header, program, _ := strings.Cut(args, "\n")
+	inputs := strings.Fields(header)
+	if len(inputs) != 2 {
+		return "Expected `test 3d A B` followed by the program"
+	}
+	a, ok1 := new(big.Int).SetString(inputs[0], 10)
+	b, ok2 := new(big.Int).SetString(inputs[1], 10)
+	if !ok1 || !ok2 {
+		return fmt.Sprintf("Invalid inputs %s %s", inputs[0], inputs[1])
+	}
+	limit := s.TickLimit
+	if limit == 0 {
+		limit = TestTickLimit
+	}
+	res, err := Run3D(program, a, b, limit)
+	if res == nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	var sb strings.Builder
+	for _, frame := range res.Trace {
+		sb.WriteString(frame)
+		sb.WriteByte('\n')
+	}
+	if err != nil {
+		fmt.Fprintf(&sb, "Error: %v\n", err)
+	} else {
+		fmt.Fprintf(&sb, "Output: %s\n", res.Output)
+	}
+	fmt.Fprintf(&sb, "Volume: %d", res.Volume)
+	return sb.String()
+}
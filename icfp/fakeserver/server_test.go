@@ -0,0 +1,169 @@
+package fakeserver
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lukehoban/icfp2024/icfp"
+	"github.com/stretchr/testify/assert"
+)
+
+const example3D = `. . . . 0 . . . .
+. B > . = . . . .
+. v 1 . . > . . .
+. . - . . . + S .
+. . . . . ^ . . .
+. . v . . 0 > . .
+. . . . . . A + .
+. 1 @ 6 . . < . .
+. . 3 . 0 @ 3 . .
+. . . . . 3 . . .`
+
+func newClient(t *testing.T) *icfp.Client {
+	return serverClient(t, New("secret", "testdata"))
+}
+
+func serverClient(t *testing.T, s *Server) *icfp.Client {
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return &icfp.Client{BaseURL: server.URL, Token: "secret", HTTPClient: server.Client()}
+}
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	s, err := c.CommunicateString(ctx, "get index")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello and welcome to the School of the Bound Variable!\n", s)
+
+	s, err = c.CommunicateString(ctx, "get missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "Unknown page missing", s)
+
+	s, err = c.CommunicateString(ctx, "echo hi there")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(s, "hi there\n\n"))
+
+	s, err = c.CommunicateString(ctx, "solve lambdaman1 LLLDURRRUDRRURR")
+	assert.NoError(t, err)
+	assert.Equal(t, "Correct, you solved lambdaman1 with a score of 33!", s)
+
+	s, err = c.CommunicateString(ctx, "solve lambdaman1 LLL")
+	assert.NoError(t, err)
+	assert.Equal(t, "Your solution for lambdaman1 is incorrect: 7 pills were not eaten", s)
+
+	s, err = c.CommunicateString(ctx, "solve spaceship1 31619")
+	assert.NoError(t, err)
+	assert.Equal(t, "Correct, you solved spaceship1 with a score of 5!", s)
+
+	s, err = c.CommunicateString(ctx, "solve spaceship1 3161")
+	assert.NoError(t, err)
+	assert.Equal(t, "Your solution for spaceship1 is incorrect: 1 squares were not visited", s)
+
+	s, err = c.CommunicateString(ctx, "solve spaceship1 30")
+	assert.NoError(t, err)
+	assert.Equal(t, "Your solution for spaceship1 is invalid: invalid move '0' at 1", s)
+
+	c.Token = "wrong"
+	_, err = c.CommunicateString(ctx, "get index")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestServerEvaluatesRequests(t *testing.T) {
+	c := newClient(t)
+	// The server evaluates requests, so "echo " . "echo " echoes "echo ".
+	expr, _ := icfp.CombineToExpr(icfp.Parse(`B. S%#(/} S%#(/}`))
+	assert.Equal(t, icfp.String("echo echo "), icfp.Eval(expr, nil))
+	res, err := c.CommunicateToken(context.Background(), `B. S%#(/} S%#(/}`)
+	assert.NoError(t, err)
+	out, _ := icfp.CombineToExpr(res)
+	assert.Equal(t, icfp.String("echo \n\nYou scored some points for using the echo service!"), icfp.Eval(out, nil))
+}
+
+func TestServerTest3D(t *testing.T) {
+	// Like the contest, `test 3d` stops after 4 ticks.
+	c := newClient(t)
+	s, err := c.CommunicateString(context.Background(), "test 3d 3 4\n"+example3D)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(s, "[t=1, x=1, y=0]\n"))
+	assert.True(t, strings.HasSuffix(s, "Error: no value submitted within 4 ticks\nVolume: 320"), s)
+
+	s, err = serverClient(t, &Server{Token: "secret", Fixtures: "testdata", TickLimit: SolveTickLimit}).CommunicateString(context.Background(), "test 3d 3 4\n"+example3D)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(s, "Output: 12\nVolume: 320"))
+
+	s, err = c.CommunicateString(context.Background(), "test 3d 3\n"+example3D)
+	assert.NoError(t, err)
+	assert.Contains(t, s, "Expected")
+}
+
+func TestRun3D(t *testing.T) {
+	res, err := Run3D(example3D, big.NewInt(3), big.NewInt(4), SolveTickLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, "12", res.Output)
+	assert.Equal(t, int64(320), res.Volume)
+
+	res, err = Run3D(example3D, big.NewInt(7), big.NewInt(0), SolveTickLimit)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", res.Output)
+
+	_, err = Run3D("1 > . < 2", big.NewInt(0), big.NewInt(0), 10)
+	assert.EqualError(t, err, "conflicting writes to (2, 0)")
+
+	_, err = Run3D("1 > .", big.NewInt(0), big.NewInt(0), 10)
+	assert.EqualError(t, err, "no operator can reduce, nothing was submitted")
+
+	_, err = Run3D("1 > . 100", big.NewInt(0), big.NewInt(0), 10)
+	assert.EqualError(t, err, `invalid token "100" at (3, 0)`)
+
+	// 3 / 2 = 1 is submitted on the first tick.
+	res, err = Run3D(". 2 .\n3 / S", big.NewInt(0), big.NewInt(0), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res.Output)
+	assert.Equal(t, int64(6), res.Volume)
+}
+
+func TestSimulators(t *testing.T) {
+	left, err := simulateLambdaman("###.#...\n...L..##\n.#######", "LLLDURRRUDRRURR")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, left)
+	_, err = simulateLambdaman("...", "R")
+	assert.EqualError(t, err, "no lambdaman in grid")
+	_, err = simulateLambdaman("L..", "RX")
+	assert.EqualError(t, err, "invalid move 'X' at 1")
+
+	left, err = simulateSpaceship("1 -1\n1 -3\n2 -5\n2 -8\n3 -10", "31619")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, left)
+	left, err = simulateSpaceship("0 0\n0 1", "8")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, left)
+}
+
+func TestServerTypedAPI(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	solve, err := c.Solve(ctx, "lambdaman1", "LLLDURRRUDRRURR")
+	assert.NoError(t, err)
+	assert.Equal(t, &icfp.SolveResult{Success: true, Problem: "lambdaman1", Score: 33, Raw: "Correct, you solved lambdaman1 with a score of 33!"}, solve)
+
+	solve, err = c.Solve(ctx, "spaceship1", "5")
+	assert.NoError(t, err)
+	assert.False(t, solve.Success)
+	assert.Equal(t, "Your solution for spaceship1 is incorrect: 5 squares were not visited", solve.Error)
+
+	test, err := c.Test3D(ctx, 3, 4, example3D)
+	assert.NoError(t, err)
+	assert.Equal(t, "", test.Output)
+	assert.Equal(t, "no value submitted within 4 ticks", test.Error)
+	assert.Len(t, test.Trace, 5)
+
+	test, err = c.Test3D(ctx, 0, 0, "1 > .")
+	assert.NoError(t, err)
+	assert.Equal(t, "no operator can reduce, nothing was submitted", test.Error)
+}
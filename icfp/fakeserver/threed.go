@@ -0,0 +1,301 @@
+package fakeserver
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+type pos struct {
+	x, y int
+}
+
+// value is a 3D cell: an operator, or an integer when op is 0.
+type value struct {
+	op byte
+	n  *big.Int
+}
+
+func (v value) equal(w value) bool {
+	if v.op != 0 || w.op != 0 {
+		return v.op == w.op
+	}
+	return v.n.Cmp(w.n) == 0
+}
+
+func (v value) String() string {
+	if v.op != 0 {
+		return string(v.op)
+	}
+	return v.n.String()
+}
+
+type board map[pos]value
+
+func (b board) copy() board {
+	ret := make(board, len(b))
+	for k, v := range b {
+		ret[k] = v
+	}
+	return ret
+}
+
+func (b board) int(p pos) (*big.Int, bool) {
+	v, ok := b[p]
+	if !ok || v.op != 0 {
+		return nil, false
+	}
+	return v.n, true
+}
+
+func (b board) bounds() (lo, hi pos) {
+	first := true
+	for p := range b {
+		if first {
+			lo, hi = p, p
+			first = false
+			continue
+		}
+		lo.x, lo.y = min(lo.x, p.x), min(lo.y, p.y)
+		hi.x, hi.y = max(hi.x, p.x), max(hi.y, p.y)
+	}
+	return lo, hi
+}
+
+func (b board) render(t int) string {
+	lo, hi := b.bounds()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[t=%d, x=%d, y=%d]\n", t, lo.x, lo.y)
+	for y := lo.y; y <= hi.y; y++ {
+		for x := lo.x; x <= hi.x; x++ {
+			if x > lo.x {
+				sb.WriteByte(' ')
+			}
+			if v, ok := b[pos{x, y}]; ok {
+				sb.WriteString(v.String())
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+const ops3D = "<>^v+-*/%@=#SAB"
+
+// parse3D parses a 3D program, substituting a and b for the A and B operators.
+func parse3D(program string, a, b *big.Int) (board, error) {
+	ret := board{}
+	for y, line := range strings.Split(strings.TrimRight(program, "\n"), "\n") {
+		for x, tok := range strings.Fields(line) {
+			switch {
+			case tok == ".":
+			case tok == "A":
+				ret[pos{x, y}] = value{n: a}
+			case tok == "B":
+				ret[pos{x, y}] = value{n: b}
+			case len(tok) == 1 && strings.Contains(ops3D, tok):
+				ret[pos{x, y}] = value{op: tok[0]}
+			default:
+				n, err := strconv.Atoi(tok)
+				if err != nil || n < -99 || n > 99 {
+					return nil, fmt.Errorf("invalid token %q at (%d, %d)", tok, x, y)
+				}
+				ret[pos{x, y}] = value{n: big.NewInt(int64(n))}
+			}
+		}
+	}
+	return ret, nil
+}
+
+// maxTrace bounds the boards kept in a Result3D trace.
+const maxTrace = 1000
+
+type Result3D struct {
+	Output string
+	Volume int64
+	Ticks  int
+	// Trace is the rendered board after each tick, up to maxTrace of them.
+	Trace []string
+}
+
+type write struct {
+	at pos
+	v  value
+}
+
+type warp struct {
+	dt int64
+	at pos
+	v  value
+}
+
+// tick performs one round of reductions, returning the next board, any time
+// warps and whether anything reduced.
+func tick(b board) (board, []warp, bool, error) {
+	var removes []pos
+	var writes []write
+	var warps []warp
+	for p, cell := range b {
+		left, up := pos{p.x - 1, p.y}, pos{p.x, p.y - 1}
+		right, down := pos{p.x + 1, p.y}, pos{p.x, p.y + 1}
+		move := func(from, to pos) {
+			if v, ok := b[from]; ok {
+				removes = append(removes, from)
+				writes = append(writes, write{to, v})
+			}
+		}
+		switch cell.op {
+		case '<':
+			move(right, left)
+		case '>':
+			move(left, right)
+		case '^':
+			move(down, up)
+		case 'v':
+			move(up, down)
+		case '+', '-', '*', '/', '%':
+			x, okx := b.int(left)
+			y, oky := b.int(up)
+			if !okx || !oky {
+				continue
+			}
+			z := new(big.Int)
+			switch cell.op {
+			case '+':
+				z.Add(x, y)
+			case '-':
+				z.Sub(x, y)
+			case '*':
+				z.Mul(x, y)
+			case '/', '%':
+				if y.Sign() == 0 {
+					return nil, nil, false, fmt.Errorf("division by zero at (%d, %d)", p.x, p.y)
+				}
+				if cell.op == '/' {
+					z.Quo(x, y)
+				} else {
+					z.Rem(x, y)
+				}
+			}
+			removes = append(removes, left, up)
+			writes = append(writes, write{right, value{n: z}}, write{down, value{n: z}})
+		case '=', '#':
+			x, okx := b[left]
+			y, oky := b[up]
+			if !okx || !oky || x.equal(y) != (cell.op == '=') {
+				continue
+			}
+			removes = append(removes, left, up)
+			writes = append(writes, write{right, y}, write{down, x})
+		case '@':
+			v, okv := b[up]
+			dx, okx := b.int(left)
+			dy, oky := b.int(right)
+			dt, okt := b.int(down)
+			if !okv || !okx || !oky || !okt {
+				continue
+			}
+			if dt.Sign() <= 0 {
+				return nil, nil, false, fmt.Errorf("time warp by %s at (%d, %d)", dt, p.x, p.y)
+			}
+			warps = append(warps, warp{dt.Int64(), pos{p.x - int(dx.Int64()), p.y - int(dy.Int64())}, v})
+		}
+	}
+	if len(removes) == 0 && len(writes) == 0 && len(warps) == 0 {
+		return b, nil, false, nil
+	}
+	next := b.copy()
+	for _, p := range removes {
+		delete(next, p)
+	}
+	written := map[pos]value{}
+	for _, w := range writes {
+		if prev, ok := written[w.at]; ok && !(b[w.at].op == 'S' && prev.equal(w.v)) {
+			return nil, nil, false, fmt.Errorf("conflicting writes to (%d, %d)", w.at.x, w.at.y)
+		}
+		written[w.at] = w.v
+		next[w.at] = w.v
+	}
+	return next, warps, true, nil
+}
+
+// Run3D runs a 3D program with inputs a and b until it submits a value,
+// reporting the spacetime volume and a trace of every board.
+func Run3D(program string, a, b *big.Int, tickLimit int) (*Result3D, error) {
+	initial, err := parse3D(program, a, b)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result3D{}
+	history := []board{initial}
+	lo, hi := initial.bounds()
+	maxT := 1
+	res.Volume = int64(hi.x-lo.x+1) * int64(hi.y-lo.y+1)
+	res.Trace = append(res.Trace, initial.render(1))
+	for res.Ticks = 1; res.Ticks <= tickLimit; res.Ticks++ {
+		cur := history[len(history)-1]
+		next, warps, changed, err := tick(cur)
+		if err != nil {
+			return res, err
+		}
+		if !changed {
+			return res, fmt.Errorf("no operator can reduce, nothing was submitted")
+		}
+		var submitted []value
+		for p, v := range cur {
+			if v.op == 'S' {
+				if w, ok := next[p]; ok && w.op != 'S' {
+					submitted = append(submitted, w)
+				}
+			}
+		}
+		if len(submitted) > 0 {
+			// The submitting tick's board doesn't count towards the volume.
+			for _, v := range submitted[1:] {
+				if !v.equal(submitted[0]) {
+					return res, fmt.Errorf("submitted different values %s and %s", submitted[0], v)
+				}
+			}
+			res.Output = submitted[0].String()
+			return res, nil
+		}
+		if len(warps) > 0 {
+			dt := warps[0].dt
+			for _, w := range warps {
+				if w.dt != dt {
+					return res, fmt.Errorf("time warps to different times in the same tick")
+				}
+			}
+			if int64(len(history)) <= dt {
+				return res, fmt.Errorf("time warp to before t=1")
+			}
+			history = history[:int64(len(history))-dt]
+			target := history[len(history)-1].copy()
+			written := map[pos]value{}
+			for _, w := range warps {
+				if prev, ok := written[w.at]; ok && !prev.equal(w.v) {
+					return res, fmt.Errorf("conflicting time warp writes to (%d, %d)", w.at.x, w.at.y)
+				}
+				written[w.at] = w.v
+				target[w.at] = w.v
+			}
+			history[len(history)-1] = target
+			next = target
+		} else {
+			history = append(history, next)
+		}
+		t := len(history)
+		maxT = max(maxT, t)
+		blo, bhi := next.bounds()
+		lo.x, lo.y = min(lo.x, blo.x), min(lo.y, blo.y)
+		hi.x, hi.y = max(hi.x, bhi.x), max(hi.y, bhi.y)
+		if len(res.Trace) < maxTrace {
+			res.Trace = append(res.Trace, next.render(t))
+		}
+		res.Volume = int64(hi.x-lo.x+1) * int64(hi.y-lo.y+1) * int64(maxT)
+	}
+	return res, fmt.Errorf("no value submitted within %d ticks", tickLimit)
+}
@@ -0,0 +1,99 @@
+package icfp
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomTerm(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		expr, typ := RandomTerm(r, GenOptions{})
+		inferred, diags := Infer(expr)
+		assert.Empty(t, diags, RenderAsLambda(expr))
+		assert.Equal(t, typ, inferred, RenderAsLambda(expr))
+		checkStrategiesAgree(t, expr)
+	}
+}
+
+func checkStrategiesAgree(t *testing.T, expr Expr) {
+	var results []string
+	for _, s := range []Strategy{CallByNeed, CallByName, CallByValue} {
+		ev := &Evaluator{Strategy: s, MaxSteps: 1_000_000}
+		v, err := ev.Eval(expr, nil)
+		if _, ok := err.(*BudgetError); ok {
+			continue
+		}
+		if !assert.NoError(t, err, "%s: %s", s, RenderAsLambda(expr)) {
+			return
+		}
+		results = append(results, RenderAsLambda(v))
+	}
+	if len(results) < 2 {
+		// Nothing to compare when the budget stopped all but one.
+		return
+	}
+	for _, res := range results[1:] {
+		assert.Equal(t, results[0], res, RenderAsLambda(expr))
+	}
+}
+
+func FuzzEval(f *testing.F) {
+	for i := int64(0); i < 10; i++ {
+		f.Add(i)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		expr, _ := RandomTerm(rand.New(rand.NewSource(seed)), GenOptions{MaxDepth: 6})
+		assert.Empty(t, Check(expr))
+		checkStrategiesAgree(t, expr)
+
+		parsed, rest := CombineToExpr(Parse(Encode(expr)))
+		assert.Empty(t, rest)
+		assert.Equal(t, expr, parsed)
+
+		rendered, err := ParseLambda(RenderAsLambda(expr))
+		assert.NoError(t, err)
+		assert.Equal(t, expr, rendered)
+
+		byts, err := MarshalExpr(expr)
+		assert.NoError(t, err)
+		unmarshaled, err := UnmarshalExpr(byts)
+		assert.NoError(t, err)
+		assert.Equal(t, expr, unmarshaled)
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add(`B$ L# B+ v# v# I$`)
+	f.Add(`? B> I# I$ S9%3 S./`)
+	f.Add(`B+ I"`)
+	f.Add(`S'%4}).$%8`)
+	f.Fuzz(func(t *testing.T, s string) {
+		// CheckString must report problems rather than panic.
+		diags := CheckString(s)
+		if len(diags) > 0 {
+			return
+		}
+		expr, rest := CombineToExpr(Parse(strings.Join(strings.Fields(s), " ")))
+		assert.Empty(t, rest)
+		parsed, rest := CombineToExpr(Parse(Encode(expr)))
+		assert.Empty(t, rest)
+		assert.Equal(t, expr, parsed)
+	})
+}
+
+func FuzzStringToken(f *testing.F) {
+	f.Add("get index")
+	f.Add("solve lambdaman1 LLRRUUDD")
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, c := range s {
+			if !strings.ContainsRune(lookup, c) {
+				return
+			}
+		}
+		assert.Equal(t, String(s), ParseToken(string(StringToToken(s))))
+	})
+}
@@ -0,0 +1,218 @@
+package icfp
+
+import (
+	"math/big"
+	"math/rand"
+)
+
+type GenOptions struct {
+	// MaxDepth bounds the nesting of generated terms; zero means 5.
+	MaxDepth int
+	// MaxLoop bounds the iterations of generated fixpoint loops; zero means 5.
+	MaxLoop int
+}
+
+type genVar struct {
+	v int64
+	t Type
+}
+
+type generator struct {
+	r    *rand.Rand
+	opts GenOptions
+	next int64
+}
+
+// RandomTerm generates a closed, well-typed term of type int, bool or string
+// (picked at random) that evaluates without error under every Strategy.
+func RandomTerm(r *rand.Rand, opts GenOptions) (Expr, Type) {
+	types := []Type{TInt{}, TBool{}, TString{}}
+	t := types[r.Intn(len(types))]
+	return RandomTermOfType(r, t, opts), t
+}
+
+// RandomTermOfType generates a closed term of t, which must be int, bool or
+// string.
+func RandomTermOfType(r *rand.Rand, t Type, opts GenOptions) Expr {
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = 5
+	}
+	if opts.MaxLoop == 0 {
+		opts.MaxLoop = 5
+	}
+	g := &generator{r: r, opts: opts}
+	return g.gen(t, opts.MaxDepth, nil)
+}
+
+func (g *generator) fresh() int64 {
+	v := g.next
+	g.next++
+	return v
+}
+
+// int builds a literal; negative numbers are negated literals since ICFP has
+// no negative integer tokens.
+func (g *generator) int(n int64) Expr {
+	if n < 0 {
+		return Unop{"-", Integer{big.NewInt(-n)}}
+	}
+	return Integer{big.NewInt(n)}
+}
+
+func (g *generator) literal(t Type) Expr {
+	switch t.(type) {
+	case TInt:
+		return g.int(g.r.Int63n(200) - 100)
+	case TBool:
+		return Boolean(g.r.Intn(2) == 0)
+	default:
+		b := make([]byte, g.r.Intn(6))
+		for i := range b {
+			b[i] = lookup[g.r.Intn(len(lookup))]
+		}
+		return String(b)
+	}
+}
+
+// nonNegative generates an int term that is never negative, for U$.
+func (g *generator) nonNegative(depth int, scope []genVar) Expr {
+	if g.r.Intn(2) == 0 {
+		return g.int(g.r.Int63n(10000))
+	}
+	return Unop{"#", g.gen(TString{}, depth-1, scope)}
+}
+
+func (g *generator) gen(t Type, depth int, scope []genVar) Expr {
+	var vars []genVar
+	for _, v := range scope {
+		if v.t == t {
+			vars = append(vars, v)
+		}
+	}
+	if depth <= 0 {
+		if len(vars) > 0 && g.r.Intn(2) == 0 {
+			return Var{v: vars[g.r.Intn(len(vars))].v}
+		}
+		return g.literal(t)
+	}
+	d := depth - 1
+	switch g.r.Intn(10) {
+	case 0:
+		return g.literal(t)
+	case 1:
+		if len(vars) > 0 {
+			return Var{v: vars[g.r.Intn(len(vars))].v}
+		}
+		return g.literal(t)
+	case 2:
+		return If{g.gen(TBool{}, d, scope), g.gen(t, d, scope), g.gen(t, d, scope)}
+	case 3:
+		// (λx.body) arg, with x of a random ground type.
+		types := []Type{TInt{}, TBool{}, TString{}}
+		xt := types[g.r.Intn(len(types))]
+		x := g.fresh()
+		arg := g.gen(xt, d, scope)
+		body := g.gen(t, d, append(scope, genVar{x, xt}))
+		return Binop{"$", Lambda{Param: x, Body: body}, arg}
+	case 4:
+		// (λf.body) (λy.int), with calls to f in body.
+		f, y := g.fresh(), g.fresh()
+		fn := Lambda{Param: y, Body: g.gen(TInt{}, d, append(scope, genVar{y, TInt{}}))}
+		body := g.gen(t, d, append(scope, genVar{f, TFunc{TInt{}, TInt{}}}))
+		return Binop{"$", Lambda{Param: f, Body: body}, fn}
+	case 5:
+		if fns := g.fns(scope); len(fns) > 0 && t == (TInt{}) {
+			return Binop{"$", Var{v: fns[g.r.Intn(len(fns))].v}, g.gen(TInt{}, d, scope)}
+		}
+		return g.loop(t, d, scope)
+	case 6:
+		return g.loop(t, d, scope)
+	}
+	switch t.(type) {
+	case TInt:
+		switch g.r.Intn(5) {
+		case 0:
+			return Unop{"-", g.gen(t, d, scope)}
+		case 1:
+			return Unop{"#", g.gen(TString{}, d, scope)}
+		case 2:
+			ops := []string{"/", "%"}
+			divisor := g.r.Int63n(20) + 1
+			if g.r.Intn(2) == 0 {
+				divisor = -divisor
+			}
+			return Binop{ops[g.r.Intn(2)], g.gen(t, d, scope), g.int(divisor)}
+		default:
+			ops := []string{"+", "-", "*"}
+			return Binop{ops[g.r.Intn(3)], g.gen(t, d, scope), g.gen(t, d, scope)}
+		}
+	case TBool:
+		switch g.r.Intn(5) {
+		case 0:
+			return Unop{"!", g.gen(t, d, scope)}
+		case 1:
+			ops := []string{"&", "|"}
+			return Binop{ops[g.r.Intn(2)], g.gen(t, d, scope), g.gen(t, d, scope)}
+		case 2:
+			types := []Type{TInt{}, TBool{}, TString{}}
+			ct := types[g.r.Intn(len(types))]
+			return Binop{"=", g.gen(ct, d, scope), g.gen(ct, d, scope)}
+		default:
+			ops := []string{"<", ">", "="}
+			return Binop{ops[g.r.Intn(3)], g.gen(TInt{}, d, scope), g.gen(TInt{}, d, scope)}
+		}
+	default:
+		switch g.r.Intn(4) {
+		case 0:
+			return Unop{"$", g.nonNegative(d, scope)}
+		case 1:
+			// Take and drop need 0 <= n <= len(s), so pad s to at least n.
+			ops := []string{"T", "D"}
+			n := g.r.Int63n(4)
+			s := Binop{".", String("abcd"[:n]), g.gen(t, d, scope)}
+			return Binop{ops[g.r.Intn(2)], g.int(n), s}
+		default:
+			return Binop{".", g.gen(t, d, scope), g.gen(t, d, scope)}
+		}
+	}
+}
+
+func (g *generator) fns(scope []genVar) []genVar {
+	var fns []genVar
+	for _, v := range scope {
+		if _, ok := v.t.(TFunc); ok {
+			fns = append(fns, v)
+		}
+	}
+	return fns
+}
+
+// zCombinator builds λf.(λx.f (λv.x x v)) (λx.f (λv.x x v)), which recurses
+// under every strategy, unlike Y which diverges when called by value.
+func (g *generator) zCombinator() Expr {
+	f := g.fresh()
+	half := func() Expr {
+		x, v := g.fresh(), g.fresh()
+		self := Binop{"$", Var{v: x}, Var{v: x}}
+		return Lambda{Param: x, Body: Binop{"$", Var{v: f}, Lambda{Param: v, Body: Binop{"$", self, Var{v: v}}}}}
+	}
+	return Lambda{Param: f, Body: Binop{"$", half(), half()}}
+}
+
+// loop builds Z (λrec.λn. if (< n 1) base (step (rec (- n 1)))) k for a
+// small literal k.
+func (g *generator) loop(t Type, d int, scope []genVar) Expr {
+	rec, n, acc := g.fresh(), g.fresh(), g.fresh()
+	inner := append(scope, genVar{n, TInt{}})
+	base := g.gen(t, d, inner)
+	step := g.gen(t, d, append(inner, genVar{acc, t}))
+	recurse := Binop{"$", Var{v: rec}, Binop{"-", Var{v: n}, g.int(1)}}
+	body := If{
+		Binop{"<", Var{v: n}, g.int(1)},
+		base,
+		Binop{"$", Lambda{Param: acc, Body: step}, recurse},
+	}
+	fn := Lambda{Param: rec, Body: Lambda{Param: n, Body: body}}
+	k := g.int(g.r.Int63n(int64(g.opts.MaxLoop) + 1))
+	return Binop{"$", Binop{"$", g.zCombinator(), fn}, k}
+}
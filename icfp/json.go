@@ -0,0 +1,156 @@
+package icfp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jsonExpr is the JSON form of an Expr. Every node has a "type" of int, bool,
+// string, if, binop, unop, lambda or var:
+//
+//	{"type": "int", "value": 42}
+//	{"type": "bool", "value": true}
+//	{"type": "string", "value": "hello"}
+//	{"type": "if", "test": {...}, "then": {...}, "else": {...}}
+//	{"type": "binop", "op": "+", "left": {...}, "right": {...}}
+//	{"type": "unop", "op": "-", "arg": {...}}
+//	{"type": "lambda", "param": 1, "body": {...}}
+//	{"type": "var", "var": 1}
+//
+// Integers may be arbitrarily large and are also accepted as strings.
+type jsonExpr struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Test  *jsonExpr       `json:"test,omitempty"`
+	Then  *jsonExpr       `json:"then,omitempty"`
+	Else  *jsonExpr       `json:"else,omitempty"`
+	Left  *jsonExpr       `json:"left,omitempty"`
+	Right *jsonExpr       `json:"right,omitempty"`
+	Arg   *jsonExpr       `json:"arg,omitempty"`
+	Param *int64          `json:"param,omitempty"`
+	Body  *jsonExpr       `json:"body,omitempty"`
+	Var   *int64          `json:"var,omitempty"`
+}
+
+func toJSON(e Expr) *jsonExpr {
+	switch v := e.(type) {
+	case Integer:
+		return &jsonExpr{Type: "int", Value: json.RawMessage(v.String())}
+	case Boolean:
+		b, _ := json.Marshal(bool(v))
+		return &jsonExpr{Type: "bool", Value: b}
+	case String:
+		b, _ := json.Marshal(string(v))
+		return &jsonExpr{Type: "string", Value: b}
+	case If:
+		return &jsonExpr{Type: "if", Test: toJSON(v.Test), Then: toJSON(v.Then), Else: toJSON(v.Else)}
+	case Binop:
+		return &jsonExpr{Type: "binop", Op: v.Op, Left: toJSON(v.Left), Right: toJSON(v.Right)}
+	case Unop:
+		return &jsonExpr{Type: "unop", Op: v.Op, Arg: toJSON(v.Arg)}
+	case Lambda:
+		param := v.Param
+		return &jsonExpr{Type: "lambda", Param: &param, Body: toJSON(v.Body)}
+	case Var:
+		n := v.v
+		return &jsonExpr{Type: "var", Var: &n}
+	default:
+		panic(fmt.Sprintf("Unknown type: %T", e))
+	}
+}
+
+func MarshalExpr(e Expr) ([]byte, error) {
+	return json.Marshal(toJSON(e))
+}
+
+func fromJSON(j *jsonExpr, path string) (Expr, error) {
+	if j == nil {
+		return nil, fmt.Errorf("%s: missing", path)
+	}
+	children := func(names ...string) ([]Expr, error) {
+		fields := map[string]*jsonExpr{"test": j.Test, "then": j.Then, "else": j.Else, "left": j.Left, "right": j.Right, "arg": j.Arg, "body": j.Body}
+		var ret []Expr
+		for _, name := range names {
+			e, err := fromJSON(fields[name], path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, e)
+		}
+		return ret, nil
+	}
+	switch j.Type {
+	case "int":
+		var n json.Number
+		if err := json.Unmarshal(j.Value, &n); err != nil {
+			return nil, fmt.Errorf("%s: bad int value %s", path, j.Value)
+		}
+		i, ok := new(big.Int).SetString(n.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("%s: bad int value %s", path, j.Value)
+		}
+		return Integer{i}, nil
+	case "bool":
+		var b bool
+		if err := json.Unmarshal(j.Value, &b); err != nil {
+			return nil, fmt.Errorf("%s: bad bool value %s", path, j.Value)
+		}
+		return Boolean(b), nil
+	case "string":
+		var s string
+		if err := json.Unmarshal(j.Value, &s); err != nil {
+			return nil, fmt.Errorf("%s: bad string value %s", path, j.Value)
+		}
+		return String(s), nil
+	case "if":
+		c, err := children("test", "then", "else")
+		if err != nil {
+			return nil, err
+		}
+		return If{c[0], c[1], c[2]}, nil
+	case "binop":
+		if !binops[j.Op] {
+			return nil, fmt.Errorf("%s: unknown binop %q", path, j.Op)
+		}
+		c, err := children("left", "right")
+		if err != nil {
+			return nil, err
+		}
+		return Binop{j.Op, c[0], c[1]}, nil
+	case "unop":
+		if !unops[j.Op] {
+			return nil, fmt.Errorf("%s: unknown unop %q", path, j.Op)
+		}
+		c, err := children("arg")
+		if err != nil {
+			return nil, err
+		}
+		return Unop{j.Op, c[0]}, nil
+	case "lambda":
+		if j.Param == nil || *j.Param < 0 {
+			return nil, fmt.Errorf("%s: lambda needs a non-negative param", path)
+		}
+		c, err := children("body")
+		if err != nil {
+			return nil, err
+		}
+		return Lambda{Param: *j.Param, Body: c[0]}, nil
+	case "var":
+		if j.Var == nil || *j.Var < 0 {
+			return nil, fmt.Errorf("%s: var needs a non-negative var", path)
+		}
+		return Var{v: *j.Var}, nil
+	default:
+		return nil, fmt.Errorf("%s: unknown type %q", path, j.Type)
+	}
+}
+
+func UnmarshalExpr(data []byte) (Expr, error) {
+	var j jsonExpr
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return fromJSON(&j, "$")
+}
@@ -0,0 +1,261 @@
+package icfp
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var unops = map[string]bool{"-": true, "!": true, "#": true, "$": true}
+var binops = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "<": true, ">": true, "=": true,
+	"|": true, "&": true, ".": true, "T": true, "D": true, "$": true, "~": true, "!": true,
+}
+
+// firstFreshVar is where variables for names outside the RenderAsLambda
+// alphabet are allocated, well clear of anything the server sends.
+const firstFreshVar = 10000
+
+// LambdaParser parses the notation produced by RenderAsLambda back into
+// expressions. Identifiers that are not bound by an enclosing lambda are
+// looked up in Globals and substituted, so Globals should hold closed terms.
+type LambdaParser struct {
+	Globals map[string]Expr
+
+	tokens []string
+	pos    int
+	names  map[string]int64
+	next   int64
+}
+
+func ParseLambda(s string) (Expr, error) {
+	p := &LambdaParser{}
+	return p.Parse(s)
+}
+
+func (p *LambdaParser) Parse(s string) (expr Expr, err error) {
+	p.tokens, err = lexLambda(s)
+	if err != nil {
+		return nil, err
+	}
+	p.pos = 0
+	p.names = map[string]int64{}
+	p.next = firstFreshVar
+	defer func() {
+		if r := recover(); r != nil {
+			expr, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	expr = p.parseSeq(nil)
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at token %d", p.tokens[p.pos], p.pos)
+	}
+	return expr, nil
+}
+
+func lexLambda(s string) ([]string, error) {
+	var tokens []string
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == 'λ' || r == '\\':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '"':
+			j := i + 1
+			for ; j < len(rs) && rs[j] != '"'; j++ {
+				if rs[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, string(rs[i:j+1]))
+			i = j + 1
+		case r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]), unicode.IsDigit(r):
+			j := i + 1
+			for j < len(rs) && unicode.IsDigit(rs[j]) {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '\'') {
+				j++
+			}
+			tokens = append(tokens, string(rs[i:j]))
+			i = j
+		case strings.ContainsRune("+-*/%<>=|&.$~!#", r):
+			tokens = append(tokens, string(r))
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *LambdaParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *LambdaParser) expect(tok string) {
+	if p.peek() != tok {
+		panic(fmt.Sprintf("expected %q at token %d, got %q", tok, p.pos, p.peek()))
+	}
+	p.pos++
+}
+
+func (p *LambdaParser) varNumber(name string) int64 {
+	for i, n := range varLookup {
+		if n == name {
+			return int64(i)
+		}
+	}
+	if len(name) > 1 && name[0] == 'v' {
+		if n, err := strconv.ParseInt(name[1:], 10, 64); err == nil {
+			return n
+		}
+	}
+	if n, ok := p.names[name]; ok {
+		return n
+	}
+	n := p.next
+	p.next++
+	p.names[name] = n
+	return n
+}
+
+// parseSeq parses juxtaposed terms as left-nested application, stopping at a
+// closing paren or the end of input.
+func (p *LambdaParser) parseSeq(bound map[string]bool) Expr {
+	var expr Expr
+	for p.peek() != "" && p.peek() != ")" {
+		arg := p.parseTerm(bound)
+		if expr == nil {
+			expr = arg
+		} else {
+			expr = Binop{"$", expr, arg}
+		}
+	}
+	if expr == nil {
+		panic(fmt.Sprintf("expected expression at token %d", p.pos))
+	}
+	return expr
+}
+
+func (p *LambdaParser) parseLambda(bound map[string]bool) Expr {
+	var params []string
+	for p.peek() != "." {
+		tok := p.peek()
+		if tok == "" || !isIdent(tok) {
+			panic(fmt.Sprintf("expected parameter at token %d, got %q", p.pos, tok))
+		}
+		params = append(params, tok)
+		p.pos++
+	}
+	p.expect(".")
+	if len(params) == 0 {
+		panic(fmt.Sprintf("lambda without parameters at token %d", p.pos))
+	}
+	inner := map[string]bool{}
+	for k := range bound {
+		inner[k] = true
+	}
+	nums := make([]int64, len(params))
+	for i, param := range params {
+		inner[param] = true
+		nums[i] = p.varNumber(param)
+	}
+	expr := p.parseSeq(inner)
+	for i := len(params) - 1; i >= 0; i-- {
+		expr = Lambda{Param: nums[i], Body: expr}
+	}
+	return expr
+}
+
+func isIdent(tok string) bool {
+	r := []rune(tok)[0]
+	return (unicode.IsLetter(r) || r == '_') && r != 'λ'
+}
+
+func (p *LambdaParser) parseTerm(bound map[string]bool) Expr {
+	tok := p.peek()
+	p.pos++
+	switch {
+	case tok == "":
+		panic("unexpected end of input")
+	case tok == "(":
+		expr := p.parseParen(bound)
+		p.expect(")")
+		return expr
+	case tok == "λ" || tok == "\\":
+		return p.parseLambda(bound)
+	case tok[0] == '"':
+		s, err := strconv.Unquote(tok)
+		if err != nil {
+			panic(fmt.Sprintf("bad string %s: %v", tok, err))
+		}
+		return String(s)
+	case tok == "true":
+		return Boolean(true)
+	case tok == "false":
+		return Boolean(false)
+	case tok[0] == '-' || unicode.IsDigit(rune(tok[0])):
+		if i, ok := new(big.Int).SetString(tok, 10); ok {
+			return Integer{i}
+		}
+		panic(fmt.Sprintf("unexpected %q at token %d", tok, p.pos-1))
+	case isIdent(tok):
+		if !bound[tok] {
+			if g, ok := p.Globals[tok]; ok {
+				return g
+			}
+		}
+		return Var{v: p.varNumber(tok)}
+	default:
+		panic(fmt.Sprintf("unexpected %q at token %d", tok, p.pos-1))
+	}
+}
+
+// parseParen parses the inside of parentheses: lambdas, if, operators
+// (unary or binary depending on operand count) and applications.
+func (p *LambdaParser) parseParen(bound map[string]bool) Expr {
+	head := p.peek()
+	switch {
+	case head == "λ" || head == "\\":
+		p.pos++
+		return p.parseLambda(bound)
+	case head == "if" && !bound[head]:
+		p.pos++
+		test := p.parseTerm(bound)
+		then := p.parseTerm(bound)
+		els := p.parseTerm(bound)
+		return If{test, then, els}
+	case (unops[head] || binops[head]) && !bound[head] && p.Globals[head] == nil:
+		p.pos++
+		var args []Expr
+		for p.peek() != ")" && p.peek() != "" {
+			args = append(args, p.parseTerm(bound))
+		}
+		if len(args) == 1 && unops[head] {
+			return Unop{head, args[0]}
+		}
+		if len(args) == 2 && binops[head] {
+			return Binop{head, args[0], args[1]}
+		}
+		panic(fmt.Sprintf("operator %q does not take %d operands", head, len(args)))
+	default:
+		return p.parseSeq(bound)
+	}
+}
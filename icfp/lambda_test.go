@@ -0,0 +1,50 @@
+package icfp
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLambdaRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		`B$ L# v# S4`,
+		`B+ I7c B* B$ B$ L" B$ L# B$ v" B$ v# v# L# B$ v" B$ v# v# L$ L% ? B= v% I! I" B+ I" B$ v$ B- v% I" I":c1+0 I!`,
+		`? B> I# I$ U$ I% U# S%`,
+		`B. S% BT I# U- I$`,
+		`L! L~ B$ v! v~`,
+	} {
+		expr, rest := CombineToExpr(Parse(s))
+		assert.Empty(t, rest)
+		parsed, err := ParseLambda(RenderAsLambda(expr))
+		assert.NoError(t, err)
+		assert.Equal(t, expr, parsed)
+		assert.Equal(t, s, Encode(expr))
+	}
+}
+
+func TestParseLambda(t *testing.T) {
+	expr, err := ParseLambda(`(λx y.(. x y)) "ab" "cd"`)
+	assert.NoError(t, err)
+	assert.Equal(t, String("abcd"), Eval(expr, nil))
+
+	expr, err = ParseLambda(`\acc n.(if (= n 0) acc (- n))`)
+	assert.NoError(t, err)
+	assert.Equal(t, `(λv10000.(λv10001.(if (= v10001 0) v10000 (- v10001))))`, RenderAsLambda(expr))
+
+	p := &LambdaParser{Globals: map[string]Expr{"two": Integer{big.NewInt(2)}}}
+	expr, err = p.Parse(`(* two ((λtwo.two) 3))`)
+	assert.NoError(t, err)
+	assert.Equal(t, Integer{big.NewInt(6)}, Eval(expr, nil))
+
+	_, err = ParseLambda(`(λx.x`)
+	assert.EqualError(t, err, `expected ")" at token 5, got ""`)
+	_, err = ParseLambda(`(# 1 2)`)
+	assert.EqualError(t, err, `operator "#" does not take 2 operands`)
+}
+
+func TestEncodeNegative(t *testing.T) {
+	assert.Equal(t, `U- I"`, Encode(Integer{big.NewInt(-1)}))
+	assert.Equal(t, `I!`, Encode(Integer{big.NewInt(0)}))
+}
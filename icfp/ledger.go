@@ -0,0 +1,212 @@
+package icfp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Submission struct {
+	Problem string `json:"problem"`
+	Answer  string `json:"answer"`
+	Solver  string `json:"solver,omitempty"`
+	// Encoded is the request to send, when it isn't the plain string.
+	Encoded string `json:"encoded,omitempty"`
+	// LocalScore is our own measure of the answer, in the same units as the
+	// server's score (lower is better).
+	LocalScore  int       `json:"local_score"`
+	ServerScore int       `json:"server_score,omitempty"`
+	Success     bool      `json:"success"`
+	Response    string    `json:"response,omitempty"`
+	Submitted   time.Time `json:"submitted"`
+}
+
+// Score is the server's score if it reported one, or else LocalScore.
+func (s Submission) Score() int {
+	if s.ServerScore > 0 {
+		return s.ServerScore
+	}
+	return s.LocalScore
+}
+
+// DefaultLocalScore is what the server will score answer to problem with, for
+// the courses where we can tell: the number of moves for spaceship and the
+// size of the encoded request for lambdaman. It is zero for other courses.
+func DefaultLocalScore(problem, answer, encoded string) int {
+	switch strings.TrimRight(problem, "0123456789") {
+	case "spaceship":
+		return len(answer)
+	case "lambdaman":
+		return len(encoded)
+	}
+	return 0
+}
+
+// Ledger is a JSON file of every submission we've made, by problem. Several
+// processes can share one: each Record merges into what's on disk.
+type Ledger struct {
+	Path string
+
+	mu       sync.Mutex
+	problems map[string][]Submission
+}
+
+type ledgerFile struct {
+	Problems map[string][]Submission `json:"problems"`
+}
+
+// DefaultLedgerPath is $ICFP_LEDGER, or solutions.json next to the config
+// file (see statePath).
+func DefaultLedgerPath() string {
+	if p := os.Getenv("ICFP_LEDGER"); p != "" {
+		return p
+	}
+	return statePath("solutions.json")
+}
+
+// OpenLedger loads the ledger at path; a missing file is an empty ledger.
+func OpenLedger(path string) (*Ledger, error) {
+	problems, err := readLedgerFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Ledger{Path: path, problems: problems}, nil
+}
+
+func readLedgerFile(path string) (map[string][]Submission, error) {
+	byts, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]Submission{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f ledgerFile
+	if err := json.Unmarshal(byts, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Problems == nil {
+		return map[string][]Submission{}, nil
+	}
+	return f.Problems, nil
+}
+
+// Best returns the lowest scoring successful submission for problem.
+func (l *Ledger) Best(problem string) (Submission, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var best Submission
+	found := false
+	for _, s := range l.problems[problem] {
+		if s.Success && (!found || s.Score() < best.Score()) {
+			best, found = s, true
+		}
+	}
+	return best, found
+}
+
+// Better reports whether an answer with localScore would beat our best
+// accepted submission for problem.
+func (l *Ledger) Better(problem string, localScore int) bool {
+	best, ok := l.Best(problem)
+	return !ok || localScore < best.Score()
+}
+
+func (l *Ledger) Submissions(problem string) []Submission {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Submission(nil), l.problems[problem]...)
+}
+
+func (l *Ledger) Problems() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ret []string
+	for p := range l.problems {
+		ret = append(ret, p)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Record adds s to the ledger and saves it, along with anything other
+// processes have recorded since we last read it.
+func (l *Ledger) Record(s Submission) error {
+	if s.Submitted.IsZero() {
+		s.Submitted = time.Now().UTC()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return withFileLock(l.Path, func() error {
+		problems, err := readLedgerFile(l.Path)
+		if err != nil {
+			return err
+		}
+		problems[s.Problem] = append(problems[s.Problem], s)
+		l.problems = problems
+		byts, err := json.MarshalIndent(ledgerFile{Problems: problems}, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(l.Path, append(byts, '\n'))
+	})
+}
+
+// staleLockAge is how old a lock file must be before we assume its process
+// died holding it. Locks are only held to read and rewrite a file.
+const staleLockAge = 10 * time.Second
+
+// withFileLock runs f holding path's lock file, path+".lock", so that
+// processes sharing path don't interleave their reads and writes of it.
+func withFileLock(path string, f func() error) error {
+	lock := path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0755); err != nil {
+		return err
+	}
+	for {
+		file, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lock)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer os.Remove(lock)
+	return f()
+}
+
+// writeFileAtomic replaces path with byts, so a crash leaves either the old
+// or the new file.
+func writeFileAtomic(path string, byts []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(byts); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
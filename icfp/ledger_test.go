@@ -0,0 +1,142 @@
+package icfp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger", "solutions.json")
+	l, err := OpenLedger(path)
+	assert.NoError(t, err)
+	_, ok := l.Best("spaceship1")
+	assert.False(t, ok)
+	assert.True(t, l.Better("spaceship1", 100))
+
+	assert.NoError(t, l.Record(Submission{Problem: "spaceship1", Answer: "31619", LocalScore: 40, ServerScore: 42, Success: true}))
+	assert.NoError(t, l.Record(Submission{Problem: "spaceship1", Answer: "3161", LocalScore: 30}))
+	assert.NoError(t, l.Record(Submission{Problem: "lambdaman1", Answer: "LLL", LocalScore: 20, Success: true}))
+
+	l, err = OpenLedger(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lambdaman1", "spaceship1"}, l.Problems())
+	assert.Len(t, l.Submissions("spaceship1"), 2)
+	best, ok := l.Best("spaceship1")
+	assert.True(t, ok)
+	assert.Equal(t, "31619", best.Answer)
+	assert.Equal(t, 42, best.Score())
+	assert.False(t, best.Submitted.IsZero())
+	assert.False(t, l.Better("spaceship1", 42))
+	assert.True(t, l.Better("spaceship1", 41))
+}
+
+func TestLedgerShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "solutions.json")
+	a, err := OpenLedger(path)
+	assert.NoError(t, err)
+	b, err := OpenLedger(path)
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, a.Record(Submission{Problem: "lambdaman1", Answer: "L", Success: true}))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, b.Record(Submission{Problem: "spaceship1", Answer: "5", Success: true}))
+		}()
+	}
+	wg.Wait()
+
+	// Neither ledger loses what the other recorded.
+	l, err := OpenLedger(path)
+	assert.NoError(t, err)
+	assert.Len(t, l.Submissions("lambdaman1"), 10)
+	assert.Len(t, l.Submissions("spaceship1"), 10)
+	assert.NoFileExists(t, path+".lock")
+}
+
+func TestClientSubmitSkipsWorseAnswers(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		byts, _ := io.ReadAll(r.Body)
+		io.WriteString(w, string(StringToToken(fmt.Sprintf("Correct, you solved lambdaman1 with a score of %d!", len(byts)))))
+	}))
+	defer server.Close()
+
+	l, err := OpenLedger(filepath.Join(t.TempDir(), "solutions.json"))
+	assert.NoError(t, err)
+	c := &Client{BaseURL: server.URL, Token: "test", Ledger: l}
+	ctx := context.Background()
+
+	res, err := c.Submit(ctx, Submission{Problem: "lambdaman1", Answer: "LLLL", Solver: "test"})
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.False(t, res.Skipped)
+	assert.Equal(t, 1, calls)
+
+	res, err = c.Solve(ctx, "lambdaman1", "LLLLL")
+	assert.NoError(t, err)
+	assert.True(t, res.Skipped)
+	assert.Equal(t, 22, res.Score)
+	assert.Equal(t, 1, calls)
+
+	res, err = c.Solve(ctx, "lambdaman1", "LLL")
+	assert.NoError(t, err)
+	assert.False(t, res.Skipped)
+	assert.Equal(t, 21, res.Score)
+	assert.Equal(t, 2, calls)
+
+	subs := l.Submissions("lambdaman1")
+	assert.Len(t, subs, 2)
+	assert.Equal(t, "test", subs[0].Solver)
+	assert.Equal(t, 22, subs[0].LocalScore)
+	assert.Equal(t, "Correct, you solved lambdaman1 with a score of 22!", subs[0].Response)
+}
+
+func TestClientSubmitScoresSpaceshipByMoves(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		byts, _ := io.ReadAll(r.Body)
+		answer := strings.TrimPrefix(DecodeRequest(string(byts)), "solve spaceship1 ")
+		io.WriteString(w, string(StringToToken(fmt.Sprintf("Correct, you solved spaceship1 with a score of %d!", len(answer)))))
+	}))
+	defer server.Close()
+
+	l, err := OpenLedger(filepath.Join(t.TempDir(), "solutions.json"))
+	assert.NoError(t, err)
+	c := &Client{BaseURL: server.URL, Token: "test", Ledger: l}
+	ctx := context.Background()
+
+	res, err := c.Solve(ctx, "spaceship1", "31619")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, res.Score)
+	assert.Equal(t, 5, l.Submissions("spaceship1")[0].LocalScore)
+
+	// Four moves beat five, even though the request is longer than five bytes.
+	res, err = c.Solve(ctx, "spaceship1", "3169")
+	assert.NoError(t, err)
+	assert.False(t, res.Skipped)
+	assert.Equal(t, 4, res.Score)
+	assert.Equal(t, 2, calls)
+
+	res, err = c.Solve(ctx, "spaceship1", "31691")
+	assert.NoError(t, err)
+	assert.True(t, res.Skipped)
+	assert.Equal(t, 2, calls)
+
+	assert.Equal(t, 0, DefaultLocalScore("3d1", "...", "S..."))
+}
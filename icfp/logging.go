@@ -0,0 +1,91 @@
+package icfp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// NewLogHandler returns a text or JSON handler writing records of level and
+// above to w.
+func NewLogHandler(w io.Writer, format, level string) (slog.Handler, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+}
+
+// SetupLogging sets the default logger to write to stderr. Empty arguments
+// come from $ICFP_LOG_FORMAT and $ICFP_LOG_LEVEL, or else text and info.
+func SetupLogging(format, level string) error {
+	if format == "" {
+		format = envOr("ICFP_LOG_FORMAT", "text")
+	}
+	if level == "" {
+		level = envOr("ICFP_LOG_LEVEL", "info")
+	}
+	h, err := NewLogHandler(os.Stderr, strings.ToLower(format), level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+type loggerKey struct{}
+
+// WithLogger makes l the logger for work done with ctx, such as a solver
+// working on one problem.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// Logger returns the logger set by WithLogger, or the default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// LogTracer logs every reduction at debug level, a structured alternative
+// to stepping through them in the debugger.
+type LogTracer struct {
+	Logger *slog.Logger
+}
+
+func (t LogTracer) Trace(e Event) {
+	ctx := context.Background()
+	if !t.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []slog.Attr{slog.String("kind", e.Kind.String()), slog.Int("depth", e.Depth)}
+	if e.Kind == EventBeta {
+		attrs = append(attrs, slog.String("var", VarName(e.Lambda.Param)), slog.String("arg", truncate(RenderAsLambda(e.Arg.Expr), 80)))
+	} else {
+		args := make([]string, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = truncate(RenderAsLambda(arg), 80)
+		}
+		attrs = append(attrs, slog.String("op", e.Op), slog.Any("args", args))
+	}
+	t.Logger.LogAttrs(ctx, slog.LevelDebug, "reduce", attrs...)
+}
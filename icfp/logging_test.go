@@ -0,0 +1,100 @@
+package icfp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &rec), line)
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestNewLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := NewLogHandler(&buf, "text", "warn")
+	assert.NoError(t, err)
+	l := slog.New(h)
+	l.Info("hidden")
+	l.Warn("shown", "problem", "lambdaman1")
+	assert.Contains(t, buf.String(), "level=WARN msg=shown problem=lambdaman1")
+	assert.NotContains(t, buf.String(), "hidden")
+
+	_, err = NewLogHandler(&buf, "xml", "info")
+	assert.EqualError(t, err, `unknown log format "xml" (want text or json)`)
+	_, err = NewLogHandler(&buf, "json", "loud")
+	assert.EqualError(t, err, `unknown log level "loud" (want debug, info, warn or error)`)
+}
+
+func TestLogTracer(t *testing.T) {
+	var buf bytes.Buffer
+	h, _ := NewLogHandler(&buf, "json", "debug")
+	expr, _ := CombineToExpr(Parse(`B$ L# B* v# I# I%`))
+	ev := &Evaluator{Tracer: LogTracer{Logger: slog.New(h)}}
+	_, err := ev.Eval(expr, nil)
+	assert.NoError(t, err)
+
+	records := logRecords(t, &buf)
+	assert.Len(t, records, 2)
+	assert.Equal(t, "beta", records[0]["kind"])
+	assert.Equal(t, "z", records[0]["var"])
+	assert.Equal(t, "4", records[0]["arg"])
+	assert.Equal(t, "*", records[1]["op"])
+	assert.Equal(t, []any{"4", "2"}, records[1]["args"])
+}
+
+func TestRunLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byts, _ := io.ReadAll(r.Body)
+		reply := "abc"
+		switch cmd := DecodeRequest(string(byts)); {
+		case cmd == "get test":
+			reply = "Test\n\n* [test1]\n"
+		case strings.HasPrefix(cmd, "solve"):
+			reply = "Correct, you solved test1 with a score of 9!"
+		}
+		io.WriteString(w, string(StringToToken(reply)))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	h, _ := NewLogHandler(&buf, "json", "info")
+	ctx := WithLogger(context.Background(), slog.New(h))
+	c := &Client{BaseURL: server.URL, Token: "test"}
+	_, err := c.Run(ctx, testSolver{}, RunOptions{})
+	assert.NoError(t, err)
+
+	records := logRecords(t, &buf)
+	last := records[len(records)-1]
+	assert.Equal(t, "problem done", last["msg"])
+	assert.Equal(t, "test", last["course"])
+	assert.Equal(t, "test1", last["problem"])
+	assert.Equal(t, "test/reverse", last["solver"])
+	assert.Equal(t, float64(3), last["bytes"])
+	assert.Equal(t, float64(9), last["score"])
+	assert.Contains(t, last, "duration")
+
+	// Requests made for the problem carry its fields too.
+	var solve map[string]any
+	for _, rec := range records {
+		if rec["msg"] == "communicate" && strings.HasPrefix(rec["command"].(string), "solve") {
+			solve = rec
+		}
+	}
+	assert.Equal(t, "test1", solve["problem"])
+	assert.Equal(t, float64(len("Correct, you solved test1 with a score of 9!")), solve["bytes"])
+}
@@ -0,0 +1,71 @@
+package icfp
+
+import (
+	"context"
+	"math/big"
+)
+
+// minRun is the shortest run worth replacing with a call to repeat, which
+// costs about as many bytes as this many characters.
+const minRun = 16
+
+// yCombinator is λf.(λx.f (x x)) (λx.f (x x)), using variables f and x.
+func yCombinator(f, x int64) Expr {
+	half := Lambda{Param: x, Body: Binop{"$", Var{v: f}, Binop{"$", Var{v: x}, Var{v: x}}}}
+	return Lambda{Param: f, Body: Binop{"$", half, half}}
+}
+
+// RunLength compresses s into a program that evaluates to s, building long
+// runs of one character with a recursive repeat function. It returns "" if
+// s has no run long enough to be worth it.
+func RunLength(s string) string {
+	// repeat = Y (λr.λc.λn. if (= n 1) c (. c (r c (- n 1))))
+	const repeat, r, c, n, f, x = 0, 1, 2, 3, 4, 5
+	one := Integer{big.NewInt(1)}
+	repeatFn := Binop{"$", yCombinator(f, x), Lambda{Param: r, Body: Lambda{Param: c, Body: Lambda{Param: n, Body: If{
+		Binop{"=", Var{v: n}, one},
+		Var{v: c},
+		Binop{".", Var{v: c}, Binop{"$", Binop{"$", Var{v: r}, Var{v: c}}, Binop{"-", Var{v: n}, one}}},
+	}}}}}
+
+	var parts []Expr
+	literal := ""
+	runs := 0
+	for i := 0; i < len(s); {
+		j := i
+		for j < len(s) && s[j] == s[i] {
+			j++
+		}
+		if j-i < minRun {
+			literal += s[i:j]
+		} else {
+			if literal != "" {
+				parts = append(parts, String(literal))
+				literal = ""
+			}
+			parts = append(parts, Binop{"$", Binop{"$", Var{v: repeat}, String(s[i : i+1])}, Integer{big.NewInt(int64(j - i))}})
+			runs++
+		}
+		i = j
+	}
+	if runs == 0 {
+		return ""
+	}
+	if literal != "" {
+		parts = append(parts, String(literal))
+	}
+	body := parts[len(parts)-1]
+	for i := len(parts) - 2; i >= 0; i-- {
+		body = Binop{".", parts[i], body}
+	}
+	return Encode(Binop{"$", Lambda{Param: repeat, Body: body}, repeatFn})
+}
+
+// Minimize returns the shortest program that evaluates to s among the plain
+// string, RunLength(s) and candidates.
+func Minimize(ctx context.Context, s string, candidates ...string) string {
+	if rl := RunLength(s); rl != "" {
+		candidates = append(candidates, rl)
+	}
+	return ShortestEncoding(ctx, s, candidates...)
+}
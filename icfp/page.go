@@ -0,0 +1,99 @@
+package icfp
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Page is a page from `get`, such as the index or a course page.
+type Page struct {
+	Title string
+	// Links are the [name] links in the order they first appear.
+	Links []string
+	// Courses are bulleted links without a number, as on the index.
+	Courses  []string
+	Problems []Problem
+}
+
+// Problem is a bulleted problem link such as
+//
+//   - [lambdaman1] Your score: 40. Best score: 33.
+//
+// Scores are zero when the page doesn't give them.
+type Problem struct {
+	ID     string
+	Course string
+	Number int
+	Solved bool
+	// Score is our score, BestScore the best score of any team.
+	Score     int
+	BestScore int
+	// Status is the text after the link.
+	Status string
+}
+
+// Gap is how far our score is from the best one, or -1 if either is unknown.
+func (p Problem) Gap() int {
+	if !p.Solved || p.Score == 0 || p.BestScore == 0 {
+		return -1
+	}
+	return p.Score - p.BestScore
+}
+
+var (
+	linkRegexp      = regexp.MustCompile(`\[([A-Za-z0-9_]+)\]`)
+	bulletRegexp    = regexp.MustCompile(`^\s*\* \[([A-Za-z0-9_]+)\]\s*(.*)$`)
+	problemRegexp   = regexp.MustCompile(`^(.*[A-Za-z_])(\d+)$`)
+	yourScoreRegexp = regexp.MustCompile(`Your score: (\d+)`)
+	bestScoreRegexp = regexp.MustCompile(`Best score: (\d+)`)
+)
+
+func ParsePage(s string) *Page {
+	page := &Page{}
+	seen := map[string]bool{}
+	for i, line := range strings.Split(s, "\n") {
+		if i == 0 {
+			page.Title = strings.TrimSpace(line)
+		}
+		for _, m := range linkRegexp.FindAllStringSubmatch(line, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				page.Links = append(page.Links, m[1])
+			}
+		}
+		m := bulletRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		pm := problemRegexp.FindStringSubmatch(m[1])
+		if pm == nil {
+			page.Courses = append(page.Courses, m[1])
+			continue
+		}
+		p := Problem{ID: m[1], Course: pm[1], Status: m[2]}
+		p.Number, _ = strconv.Atoi(pm[2])
+		if sm := yourScoreRegexp.FindStringSubmatch(m[2]); sm != nil {
+			p.Solved = true
+			p.Score, _ = strconv.Atoi(sm[1])
+		}
+		if bm := bestScoreRegexp.FindStringSubmatch(m[2]); bm != nil {
+			p.BestScore, _ = strconv.Atoi(bm[1])
+		}
+		if strings.Contains(m[2], "You solved it") || strings.Contains(m[2], "[Solved]") {
+			p.Solved = true
+		}
+		page.Problems = append(page.Problems, p)
+	}
+	return page
+}
+
+// Problems fetches a course page and returns its problems.
+func (c *Client) Problems(ctx context.Context, course string) ([]Problem, error) {
+	s, err := c.Get(ctx, course)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePage(s).Problems, nil
+}
@@ -0,0 +1,51 @@
+package icfp
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePage(t *testing.T) {
+	byts, err := os.ReadFile("../spaceship/spaceship.md")
+	assert.NoError(t, err)
+	page := ParsePage(string(byts))
+	assert.Equal(t, "Welcome to the Spaceship course!", page.Title)
+	assert.Len(t, page.Problems, 25)
+	assert.Equal(t, Problem{ID: "spaceship1", Course: "spaceship", Number: 1, BestScore: 5, Status: "Best score: 5."}, page.Problems[0])
+	assert.Equal(t, 799788, page.Problems[23].BestScore)
+	assert.Empty(t, page.Courses)
+
+	page = ParsePage(`Hello and welcome to the School of the Bound Variable!
+
+You're now looking at the [index]. You can use our [echo] service and look at the [scoreboard].
+
+ * [lambdaman]
+ * [spaceship]
+ * [3d]
+
+You may also take our [language_test].`)
+	assert.Equal(t, []string{"index", "echo", "scoreboard", "lambdaman", "spaceship", "3d", "language_test"}, page.Links)
+	assert.Equal(t, []string{"lambdaman", "spaceship", "3d"}, page.Courses)
+	assert.Empty(t, page.Problems)
+}
+
+func TestParsePageSolved(t *testing.T) {
+	page := ParsePage(`Welcome to the Lambda-Man course.
+* [lambdaman1] Your score: 40. Best score: 33.
+* [lambdaman2] Best score: 44.
+* [3d3] You solved it.
+* [efficiency4] At least one other team solved it.`)
+	assert.Len(t, page.Problems, 4)
+	assert.True(t, page.Problems[0].Solved)
+	assert.Equal(t, 40, page.Problems[0].Score)
+	assert.Equal(t, 7, page.Problems[0].Gap())
+	assert.False(t, page.Problems[1].Solved)
+	assert.Equal(t, -1, page.Problems[1].Gap())
+	assert.True(t, page.Problems[2].Solved)
+	assert.Equal(t, "3d", page.Problems[2].Course)
+	assert.Equal(t, 3, page.Problems[2].Number)
+	assert.False(t, page.Problems[3].Solved)
+	assert.Equal(t, "At least one other team solved it.", page.Problems[3].Status)
+}
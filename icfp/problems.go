@@ -0,0 +1,128 @@
+package icfp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Problems downloaded by FetchProblems are kept as
+// <dir>/<course>/<id>.txt, next to <id>.json with their ProblemMeta.
+const DefaultProblemsDir = "problems"
+
+type ProblemMeta struct {
+	ID        string    `json:"id"`
+	Course    string    `json:"course"`
+	Number    int       `json:"number"`
+	Fetched   time.Time `json:"fetched"`
+	Score     int       `json:"score,omitempty"`
+	BestScore int       `json:"best_score,omitempty"`
+}
+
+type FetchResult struct {
+	Problem Problem
+	Path    string
+	// Skipped is set when the file already existed and wasn't refetched.
+	Skipped bool
+	Err     error
+}
+
+func problemPath(dir, course, id string) string {
+	return filepath.Join(dir, course, id+".txt")
+}
+
+// FetchProblems downloads every problem listed on course's page into dir,
+// skipping problems already there unless force is set.
+func (c *Client) FetchProblems(ctx context.Context, dir, course string, force bool) ([]FetchResult, error) {
+	problems, err := c.Problems(ctx, course)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) == 0 {
+		return nil, fmt.Errorf("no problems listed for %s", course)
+	}
+	var results []FetchResult
+	for _, p := range problems {
+		res := FetchResult{Problem: p, Path: problemPath(dir, course, p.ID)}
+		if _, err := os.Stat(res.Path); err == nil && !force {
+			res.Skipped = true
+		} else {
+			res.Err = c.fetchProblem(ctx, res.Path, p)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (c *Client) fetchProblem(ctx context.Context, path string, p Problem) error {
+	input, err := c.Get(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(ProblemMeta{ID: p.ID, Course: p.Course, Number: p.Number, Fetched: time.Now().UTC(), Score: p.Score, BestScore: p.BestScore}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(path[:len(path)-len(".txt")]+".json", append(meta, '\n'), 0644)
+}
+
+// ProblemsDir is $ICFP_PROBLEMS, or else the nearest problems directory in
+// the current directory or one of its parents, so tests in any package can
+// find it.
+func ProblemsDir() (string, error) {
+	if dir := os.Getenv("ICFP_PROBLEMS"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, DefaultProblemsDir)
+		if fi, err := os.Stat(candidate); err == nil && fi.IsDir() {
+			return candidate, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("no problems directory found; run `go run . fetch`")
+		}
+		dir = parent
+	}
+}
+
+// LoadProblem reads a downloaded problem by name, such as "spaceship1".
+func LoadProblem(name string) (string, *ProblemMeta, error) {
+	m := problemRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return "", nil, fmt.Errorf("bad problem name %q", name)
+	}
+	dir, err := ProblemsDir()
+	if err != nil {
+		return "", nil, err
+	}
+	path := problemPath(dir, m[1], name)
+	byts, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, fmt.Errorf("problem %s isn't downloaded; run `go run . fetch -courses %s`", name, m[1])
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	meta := &ProblemMeta{ID: name, Course: m[1]}
+	if mb, err := os.ReadFile(path[:len(path)-len(".txt")] + ".json"); err == nil {
+		if err := json.Unmarshal(mb, meta); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return string(byts), meta, nil
+}
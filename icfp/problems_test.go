@@ -0,0 +1,73 @@
+package icfp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchProblems(t *testing.T) {
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byts, _ := io.ReadAll(r.Body)
+		page := strings.TrimPrefix(DecodeRequest(string(byts)), "get ")
+		gets++
+		reply := "Unknown page " + page
+		switch page {
+		case "lambdaman":
+			reply = "Lambdaman\n\n* [lambdaman1] Your score: 40. Best score: 33.\n* [lambdaman2]\n"
+		case "lambdaman1", "lambdaman2":
+			reply = "###\n#L.\n###" + page
+		}
+		io.WriteString(w, string(StringToToken(reply)))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	c := &Client{BaseURL: server.URL, Token: "test"}
+	results, err := c.FetchProblems(context.Background(), dir, "lambdaman", false)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, filepath.Join(dir, "lambdaman", "lambdaman1.txt"), results[0].Path)
+	assert.False(t, results[0].Skipped)
+	assert.Equal(t, 3, gets)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "lambdaman", "lambdaman2.txt")))
+	results, err = c.FetchProblems(context.Background(), dir, "lambdaman", false)
+	assert.NoError(t, err)
+	assert.True(t, results[0].Skipped)
+	assert.False(t, results[1].Skipped)
+	assert.Equal(t, 5, gets)
+
+	_, err = c.FetchProblems(context.Background(), dir, "nothing", false)
+	assert.EqualError(t, err, "no problems listed for nothing")
+
+	t.Setenv("ICFP_PROBLEMS", dir)
+	input, meta, err := LoadProblem("lambdaman1")
+	assert.NoError(t, err)
+	assert.Equal(t, "###\n#L.\n###lambdaman1", input)
+	assert.Equal(t, 40, meta.Score)
+	assert.Equal(t, 33, meta.BestScore)
+	assert.Equal(t, 1, meta.Number)
+	assert.False(t, meta.Fetched.IsZero())
+
+	_, _, err = LoadProblem("lambdaman3")
+	assert.EqualError(t, err, "problem lambdaman3 isn't downloaded; run `go run . fetch -courses lambdaman`")
+	_, _, err = LoadProblem("index")
+	assert.EqualError(t, err, `bad problem name "index"`)
+}
+
+func TestProblemsDir(t *testing.T) {
+	t.Setenv("ICFP_PROBLEMS", "")
+	dir, err := ProblemsDir()
+	assert.NoError(t, err)
+	abs, _ := filepath.Abs(filepath.Join("..", DefaultProblemsDir))
+	assert.Equal(t, abs, dir)
+}
@@ -0,0 +1,337 @@
+package icfp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// ProfileCounts is the work done in some part of an evaluation. Allocs counts
+// the environments, thunks and integer and string values the evaluator
+// creates, rather than what the Go runtime allocates.
+type ProfileCounts struct {
+	Steps  int64 `json:"steps"`
+	Betas  int64 `json:"betas"`
+	Allocs int64 `json:"allocs"`
+}
+
+func (c *ProfileCounts) add(o ProfileCounts) {
+	c.Steps += o.Steps
+	c.Betas += o.Betas
+	c.Allocs += o.Allocs
+}
+
+// ProfileFunc is a lambda in the profiled program. Flat is the work done in
+// its body, and Cum also includes the lambdas it calls. Beta reductions are
+// charged to the lambda being applied.
+type ProfileFunc struct {
+	// Pos is the index of the lambda's L token in the encoded program,
+	// starting at 1.
+	Pos       int           `json:"pos"`
+	Signature string        `json:"signature"`
+	Flat      ProfileCounts `json:"flat"`
+	Cum       ProfileCounts `json:"cum"`
+}
+
+// Name identifies the lambda in reports and pprof profiles.
+func (f *ProfileFunc) Name() string {
+	if f.Pos == 0 {
+		return f.Signature
+	}
+	return fmt.Sprintf("%s @%d", f.Signature, f.Pos)
+}
+
+// profNode is a lambda and the chain of lambdas it was called from, like a
+// stack frame. Recursive calls reuse the node of the earlier call, so chains
+// never hold a lambda twice.
+type profNode struct {
+	fn       *ProfileFunc
+	parent   *profNode
+	children map[*ProfileFunc]*profNode
+	self     ProfileCounts
+}
+
+func (n *profNode) push(fn *ProfileFunc) *profNode {
+	for m := n; m != nil; m = m.parent {
+		if m.fn == fn {
+			return m
+		}
+	}
+	if child, ok := n.children[fn]; ok {
+		return child
+	}
+	child := &profNode{fn: fn, parent: n, children: map[*ProfileFunc]*profNode{}}
+	n.children[fn] = child
+	return child
+}
+
+// Profile attributes the steps, beta reductions and allocations of
+// evaluations to the lambdas they happen in. Set Evaluator.Profile to a
+// NewProfile to collect one; it accumulates over calls to Eval. The cost of
+// evaluating a lazy argument goes to the lambda that passed it, wherever it
+// is forced.
+type Profile struct {
+	Duration time.Duration
+
+	root  *profNode
+	funcs map[int]*ProfileFunc
+	start time.Time
+}
+
+func NewProfile() *Profile {
+	top := &ProfileFunc{Signature: "(top level)"}
+	return &Profile{
+		root:  &profNode{fn: top, children: map[*ProfileFunc]*profNode{}},
+		funcs: map[int]*ProfileFunc{},
+		start: time.Now(),
+	}
+}
+
+// fn returns the ProfileFunc of l, which annotateLambdas has given a
+// position unless it came from outside the program.
+func (p *Profile) fn(l Lambda) *ProfileFunc {
+	if f, ok := p.funcs[l.Pos]; ok {
+		return f
+	}
+	sig := "(unknown)"
+	if l.Pos != 0 {
+		sig = truncate(RenderAsLambda(Lambda{Param: l.Param, Body: l.Body}), 60)
+	}
+	f := &ProfileFunc{Pos: l.Pos, Signature: sig}
+	p.funcs[l.Pos] = f
+	return f
+}
+
+// annotateLambdas returns a copy of e with each lambda's Pos set to the
+// index of its token, counting from start.
+func annotateLambdas(e Expr, start int) (Expr, int) {
+	switch v := e.(type) {
+	case Lambda:
+		body, next := annotateLambdas(v.Body, start+1)
+		return Lambda{Param: v.Param, Body: body, Env: v.Env, Pos: start}, next
+	case Binop:
+		left, next := annotateLambdas(v.Left, start+1)
+		right, next := annotateLambdas(v.Right, next)
+		return Binop{v.Op, left, right}, next
+	case Unop:
+		arg, next := annotateLambdas(v.Arg, start+1)
+		return Unop{v.Op, arg}, next
+	case If:
+		test, next := annotateLambdas(v.Test, start+1)
+		then, next := annotateLambdas(v.Then, next)
+		els, next := annotateLambdas(v.Else, next)
+		return If{test, then, els}, next
+	}
+	return e, start + 1
+}
+
+// Funcs returns the lambdas that did any work, and the top level, by
+// descending flat steps.
+func (p *Profile) Funcs() []*ProfileFunc {
+	seen := map[*ProfileFunc]bool{}
+	var funcs []*ProfileFunc
+	p.walk(func(n *profNode) {
+		if !seen[n.fn] {
+			seen[n.fn] = true
+			n.fn.Flat, n.fn.Cum = ProfileCounts{}, ProfileCounts{}
+			funcs = append(funcs, n.fn)
+		}
+	})
+	p.walk(func(n *profNode) {
+		n.fn.Flat.add(n.self)
+		// Chains never repeat a lambda, so this counts each once.
+		for m := n; m != nil; m = m.parent {
+			m.fn.Cum.add(n.self)
+		}
+	})
+	sort.SliceStable(funcs, func(i, j int) bool {
+		if funcs[i].Flat.Steps != funcs[j].Flat.Steps {
+			return funcs[i].Flat.Steps > funcs[j].Flat.Steps
+		}
+		return funcs[i].Pos < funcs[j].Pos
+	})
+	return funcs
+}
+
+func (p *Profile) walk(f func(*profNode)) {
+	var visit func(n *profNode)
+	visit = func(n *profNode) {
+		f(n)
+		fns := make([]*ProfileFunc, 0, len(n.children))
+		for fn := range n.children {
+			fns = append(fns, fn)
+		}
+		sort.Slice(fns, func(i, j int) bool { return fns[i].Pos < fns[j].Pos })
+		for _, fn := range fns {
+			visit(n.children[fn])
+		}
+	}
+	visit(p.root)
+}
+
+// WriteReport prints the top lambdas by flat steps, or all of them if top is
+// zero.
+func (p *Profile) WriteReport(w io.Writer, top int) error {
+	funcs := p.Funcs()
+	total := p.root.fn.Cum
+	fmt.Fprintf(w, "%d steps, %d beta reductions, %d allocations in %d lambdas\n", total.Steps, total.Betas, total.Allocs, len(funcs)-1)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "flat\tflat%\tcum\tcum%\tbetas\tallocs\tpos\tlambda\t")
+	for i, f := range funcs {
+		if top > 0 && i >= top {
+			break
+		}
+		pos := "-"
+		if f.Pos != 0 {
+			pos = fmt.Sprint(f.Pos)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%d\t%d\t%s\t%s\t\n", f.Flat.Steps, percent(f.Flat.Steps, total.Steps), f.Cum.Steps, percent(f.Cum.Steps, total.Steps), f.Flat.Betas, f.Flat.Allocs, pos, f.Signature)
+	}
+	return tw.Flush()
+}
+
+func percent(n, total int64) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// go tool pprof, with steps, betas and allocs as sample types.
+func (p *Profile) WritePprof(w io.Writer) error {
+	funcs := p.Funcs()
+	ids := map[*ProfileFunc]uint64{}
+	for i, f := range funcs {
+		ids[f] = uint64(i + 1)
+	}
+	strs := &stringTable{index: map[string]int64{}}
+	strs.add("")
+
+	var prof protoBuffer
+	for _, typ := range []string{"steps", "betas", "allocs"} {
+		var vt protoBuffer
+		vt.int(1, strs.add(typ))
+		vt.int(2, strs.add("count"))
+		prof.message(1, &vt)
+	}
+	p.walk(func(n *profNode) {
+		if n.self == (ProfileCounts{}) {
+			return
+		}
+		var locs []uint64
+		for m := n; m != nil; m = m.parent {
+			locs = append(locs, ids[m.fn])
+		}
+		var sample protoBuffer
+		sample.packedUints(1, locs)
+		sample.packedInts(2, []int64{n.self.Steps, n.self.Betas, n.self.Allocs})
+		prof.message(2, &sample)
+	})
+	for _, f := range funcs {
+		var line, loc protoBuffer
+		line.uint(1, ids[f])
+		line.int(2, int64(f.Pos))
+		loc.uint(1, ids[f])
+		loc.message(4, &line)
+		prof.message(4, &loc)
+	}
+	for _, f := range funcs {
+		var fn protoBuffer
+		fn.uint(1, ids[f])
+		fn.int(2, strs.add(f.Name()))
+		// pprof would try to demangle the name if it was the same as the
+		// system name.
+		fn.int(3, strs.add(fmt.Sprintf("lambda%d", f.Pos)))
+		fn.int(4, strs.add("program"))
+		fn.int(5, int64(f.Pos))
+		prof.message(5, &fn)
+	}
+	// The string table has to follow everything that adds to it.
+	var period protoBuffer
+	period.int(1, strs.add("steps"))
+	period.int(2, strs.add("count"))
+	defaultType := strs.add("steps")
+	for _, s := range strs.strings {
+		prof.bytes(6, []byte(s))
+	}
+	prof.int(9, p.start.UnixNano())
+	prof.int(10, int64(p.Duration))
+	prof.message(11, &period)
+	prof.int(12, 1)
+	prof.int(14, defaultType)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(prof.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type stringTable struct {
+	strings []string
+	index   map[string]int64
+}
+
+func (t *stringTable) add(s string) int64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.index[s] = i
+	return i
+}
+
+// protoBuffer encodes just enough of the protocol buffer wire format for
+// pprof profiles.
+type protoBuffer struct {
+	bytes.Buffer
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+func (b *protoBuffer) uint(field int, x uint64) {
+	b.varint(uint64(field)<<3 | 0)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int(field int, x int64) {
+	b.uint(field, uint64(x))
+}
+
+func (b *protoBuffer) bytes(field int, byts []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(byts)))
+	b.Write(byts)
+}
+
+func (b *protoBuffer) message(field int, m *protoBuffer) {
+	b.bytes(field, m.Bytes())
+}
+
+func (b *protoBuffer) packedUints(field int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.bytes(field, packed.Bytes())
+}
+
+func (b *protoBuffer) packedInts(field int, xs []int64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.bytes(field, packed.Bytes())
+}
@@ -0,0 +1,65 @@
+package icfp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile(t *testing.T) {
+	expr, err := ParseLambda(`(λf.(f (f 3))) (λx.(* x 2))`)
+	assert.NoError(t, err)
+	p := NewProfile()
+	ev := &Evaluator{Profile: p}
+	res, err := ev.Eval(expr, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "12", RenderAsLambda(res))
+
+	funcs := p.Funcs()
+	assert.Len(t, funcs, 3)
+	double, twice, top := funcs[0], funcs[1], funcs[2]
+	assert.Equal(t, "(λx.(* x 2)) @8", double.Name())
+	assert.Equal(t, ProfileCounts{Steps: 6, Betas: 2, Allocs: 6}, double.Flat)
+	assert.Equal(t, "(λf.(f (f 3))) @2", twice.Name())
+	// Forcing (f 3) inside double is charged to twice, which passed it.
+	assert.Equal(t, int64(5), twice.Flat.Steps)
+	assert.Equal(t, int64(11), twice.Cum.Steps)
+	assert.Equal(t, "(top level)", top.Name())
+	assert.Equal(t, ev.Stats.Steps, top.Cum.Steps)
+	assert.Equal(t, ev.Stats.BetaReductions, top.Cum.Betas)
+
+	// Parsing doesn't number lambdas, only profiling does.
+	assert.Equal(t, 0, expr.(Binop).Left.(Lambda).Pos)
+
+	var report bytes.Buffer
+	assert.NoError(t, p.WriteReport(&report, 2))
+	assert.Contains(t, report.String(), "14 steps, 3 beta reductions, 10 allocations in 2 lambdas\n")
+	assert.Regexp(t, `6 +42.9% +6 +42.9% +2 +6 +8 +\(λx.\(\* x 2\)\)`, report.String())
+	assert.NotContains(t, report.String(), "top level")
+
+	var buf bytes.Buffer
+	assert.NoError(t, p.WritePprof(&buf))
+	gz, err := gzip.NewReader(&buf)
+	assert.NoError(t, err)
+	byts, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Contains(t, string(byts), "(λx.(* x 2)) @8")
+	assert.Contains(t, string(byts), "allocs")
+}
+
+func TestProfileRecursion(t *testing.T) {
+	p := NewProfile()
+	ev := &Evaluator{Profile: p}
+	_, err := ev.Eval(loadBenchCase(t, "deep_recursion"), nil)
+	assert.NoError(t, err)
+	// Recursive calls share a frame, so nothing is counted twice.
+	for _, f := range p.Funcs() {
+		assert.LessOrEqual(t, f.Cum.Steps, ev.Stats.Steps, f.Name())
+		if f.Name() == "(top level)" {
+			assert.Equal(t, ev.Stats.Steps, f.Cum.Steps)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package icfp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type QueueState string
+
+const (
+	// Pending submissions haven't been sent, or were being sent when we
+	// last stopped and will be sent again.
+	QueuePending  QueueState = "pending"
+	QueueSent     QueueState = "sent"
+	QueueAccepted QueueState = "accepted"
+	QueueRejected QueueState = "rejected"
+	// Skipped submissions weren't sent because the ledger has a better one.
+	QueueSkipped QueueState = "skipped"
+	// Failed submissions got an error that retrying won't fix, such as a
+	// 4xx, or had no reply maxQueueAttempts times. Queue.Retry makes them
+	// pending again.
+	QueueFailed QueueState = "failed"
+)
+
+const maxQueueAttempts = 5
+
+// done reports whether the ledger has the outcome, so the item needn't be
+// kept.
+func (s QueueState) done() bool {
+	return s == QueueAccepted || s == QueueRejected || s == QueueSkipped
+}
+
+type QueueItem struct {
+	ID         int `json:"id"`
+	Submission `json:"submission"`
+	State      QueueState `json:"state"`
+	Attempts   int        `json:"attempts"`
+	// Owner is the process ID sending a sent item.
+	Owner int `json:"owner,omitempty"`
+	// Error is why the last attempt failed to get a reply.
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Queue is a JSON file of submissions, saved before each is sent so that
+// answers survive the process dying. Several processes can share one: every
+// change is made to what's on disk, holding its lock file.
+type Queue struct {
+	Path string
+
+	mu    sync.Mutex
+	items []*QueueItem
+}
+
+var errNotPending = errors.New("not pending")
+
+type queueFile struct {
+	NextID int          `json:"next_id"`
+	Items  []*QueueItem `json:"items"`
+}
+
+// DefaultQueuePath is $ICFP_QUEUE, or queue.json next to the config file
+// (see statePath).
+func DefaultQueuePath() string {
+	if p := os.Getenv("ICFP_QUEUE"); p != "" {
+		return p
+	}
+	return statePath("queue.json")
+}
+
+// OpenQueue loads the queue at path; a missing file is an empty queue.
+// Submissions left in the sent state by a process that's no longer running
+// never got a reply, so they are pending again; OpenQueue assumes nothing
+// else in this process is using the queue. Finished submissions are dropped,
+// since the ledger records them, so the file only holds what was added
+// since.
+func OpenQueue(path string) (*Queue, error) {
+	q := &Queue{Path: path}
+	err := q.modify(func(f *queueFile) (bool, error) {
+		changed := false
+		kept := []*QueueItem{}
+		for _, item := range f.Items {
+			if item.State.done() {
+				changed = true
+				continue
+			}
+			if item.State == QueueSent && (item.Owner == os.Getpid() || !processRunning(item.Owner)) {
+				item.State, item.Owner = QueuePending, 0
+				changed = true
+			}
+			kept = append(kept, item)
+		}
+		f.Items = kept
+		return changed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// processRunning reports whether the process pid is still running.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks that the process exists. Where it isn't
+	// supported, FindProcess has already failed for missing processes.
+	return !errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
+
+func readQueueFile(path string) (*queueFile, error) {
+	f := &queueFile{}
+	byts, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(byts, f); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	// Files from before next_id was saved number from their last item.
+	f.NextID = max(f.NextID, 1)
+	for _, item := range f.Items {
+		f.NextID = max(f.NextID, item.ID+1)
+	}
+	return f, nil
+}
+
+// modify applies f to the queue as it is on disk and saves the result if f
+// reports a change, holding the lock file throughout.
+func (q *Queue) modify(f func(*queueFile) (bool, error)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return withFileLock(q.Path, func() error {
+		file, err := readQueueFile(q.Path)
+		if err != nil {
+			return err
+		}
+		changed, err := f(file)
+		if err != nil {
+			return err
+		}
+		q.items = file.Items
+		if !changed {
+			return nil
+		}
+		byts, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(q.Path, append(byts, '\n'))
+	})
+}
+
+// Add saves sub as pending and returns its ID.
+func (q *Queue) Add(sub Submission) (int, error) {
+	var id int
+	err := q.modify(func(f *queueFile) (bool, error) {
+		id = f.NextID
+		f.NextID++
+		f.Items = append(f.Items, &QueueItem{ID: id, Submission: sub, State: QueuePending, Updated: time.Now().UTC()})
+		return true, nil
+	})
+	return id, err
+}
+
+// Items returns copies of the items in the order they were added, as of the
+// last change this process made.
+func (q *Queue) Items() []QueueItem {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ret := make([]QueueItem, len(q.items))
+	for i, item := range q.items {
+		ret[i] = *item
+	}
+	return ret
+}
+
+// Pending returns the IDs of the pending items.
+func (q *Queue) Pending() []int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var ids []int
+	for _, item := range q.items {
+		if item.State == QueuePending {
+			ids = append(ids, item.ID)
+		}
+	}
+	return ids
+}
+
+// Clean drops every item that won't be sent again: finished ones, which the
+// ledger also records, and failed ones.
+func (q *Queue) Clean() error {
+	return q.modify(func(f *queueFile) (bool, error) {
+		kept := []*QueueItem{}
+		for _, item := range f.Items {
+			if item.State == QueuePending || item.State == QueueSent {
+				kept = append(kept, item)
+			}
+		}
+		f.Items = kept
+		return true, nil
+	})
+}
+
+// Retry makes failed items pending again, for instance after fixing the
+// token, and returns how many there were.
+func (q *Queue) Retry() (int, error) {
+	n := 0
+	err := q.modify(func(f *queueFile) (bool, error) {
+		for _, item := range f.Items {
+			if item.State == QueueFailed {
+				item.State, item.Attempts = QueuePending, 0
+				item.Updated = time.Now().UTC()
+				n++
+			}
+		}
+		return n > 0, nil
+	})
+	return n, err
+}
+
+// update applies f to the item with id and saves the queue.
+func (q *Queue) update(id int, f func(*QueueItem) error) error {
+	return q.modify(func(file *queueFile) (bool, error) {
+		for _, item := range file.Items {
+			if item.ID == id {
+				if err := f(item); err != nil {
+					return false, err
+				}
+				item.Updated = time.Now().UTC()
+				return true, nil
+			}
+		}
+		return false, fmt.Errorf("no queued submission %d", id)
+	})
+}
+
+// send submits the pending item id with c, recording the outcome. If there
+// is no reply the item stays pending, to be retried by Drain, unless the
+// error is permanent or it has run out of attempts.
+func (q *Queue) send(ctx context.Context, c *Client, id int) (*SolveResult, error) {
+	var sub Submission
+	err := q.update(id, func(item *QueueItem) error {
+		if item.State != QueuePending {
+			return fmt.Errorf("queued submission %d is %s: %w", id, item.State, errNotPending)
+		}
+		item.State, item.Owner = QueueSent, os.Getpid()
+		item.Attempts++
+		sub = item.Submission
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.submit(ctx, sub)
+	if uerr := q.update(id, func(item *QueueItem) error {
+		item.Owner = 0
+		switch {
+		case err != nil:
+			item.State, item.Error = QueuePending, err.Error()
+			var statusErr *StatusError
+			if (errors.As(err, &statusErr) && !statusErr.Temporary()) || item.Attempts >= maxQueueAttempts {
+				item.State = QueueFailed
+			}
+		case res.Skipped:
+			item.State, item.Error = QueueSkipped, ""
+		case res.Success:
+			item.State, item.Error = QueueAccepted, ""
+		default:
+			item.State, item.Error = QueueRejected, ""
+		}
+		if res != nil && !res.Skipped {
+			item.Response = res.Raw
+			item.ServerScore = res.Score
+			item.Success = res.Success
+		}
+		return nil
+	}); uerr != nil && err == nil {
+		err = uerr
+	}
+	return res, err
+}
+
+// Drain sends every pending submission in turn, stopping at the first that
+// gets no reply and is still pending, since the server is probably
+// unreachable. Submissions that fail for good are logged and skipped. It
+// returns how many got a reply.
+func (q *Queue) Drain(ctx context.Context, c *Client) (int, error) {
+	sent := 0
+	for _, id := range q.Pending() {
+		_, err := q.send(ctx, c, id)
+		if errors.Is(err, errNotPending) {
+			// Sent concurrently by Submit.
+			continue
+		}
+		if err != nil && q.state(id) == QueueFailed {
+			Logger(ctx).Warn("queued submission failed", "id", id, "err", err)
+			continue
+		}
+		if err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (q *Queue) state(id int) QueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range q.items {
+		if item.ID == id {
+			return item.State
+		}
+	}
+	return ""
+}
@@ -0,0 +1,231 @@
+package icfp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := OpenQueue(path)
+	assert.NoError(t, err)
+	id, err := q.Add(Submission{Problem: "lambdaman1", Answer: "LLL"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	id, err = q.Add(Submission{Problem: "lambdaman2", Answer: "RRR"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+	assert.NoError(t, q.update(1, func(item *QueueItem) error {
+		item.State = QueueSent
+		return nil
+	}))
+
+	// A submission that was being sent when the process died is pending
+	// again.
+	q, err = OpenQueue(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, q.Pending())
+	id, err = q.Add(Submission{Problem: "lambdaman3", Answer: "UUU"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+
+	// One being sent by another running process is left alone.
+	assert.NoError(t, q.update(1, func(item *QueueItem) error {
+		item.State, item.Owner = QueueSent, os.Getppid()
+		return nil
+	}))
+	q, err = OpenQueue(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, q.Pending())
+}
+
+func TestQueueShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		q, err := OpenQueue(path)
+		assert.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				_, err := q.Add(Submission{Problem: "lambdaman1", Answer: "LLL"})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	q, err := OpenQueue(path)
+	assert.NoError(t, err)
+	ids := map[int]bool{}
+	for _, item := range q.Items() {
+		ids[item.ID] = true
+	}
+	assert.Len(t, ids, 20)
+}
+
+func TestClientSubmitQueued(t *testing.T) {
+	up := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		byts, _ := io.ReadAll(r.Body)
+		fields := strings.Fields(DecodeRequest(string(byts)))
+		reply := "Your solution for " + fields[1] + " is incorrect"
+		if fields[2] == "LLL" {
+			reply = "Correct, you solved " + fields[1] + " with a score of 12!"
+		}
+		io.WriteString(w, string(StringToToken(reply)))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := OpenQueue(path)
+	assert.NoError(t, err)
+	c := &Client{BaseURL: server.URL, Token: "test", Queue: q}
+	ctx := context.Background()
+
+	_, err = c.Submit(ctx, Submission{Problem: "lambdaman1", Answer: "LLL", Solver: "test"})
+	assert.Error(t, err)
+	_, err = c.Submit(ctx, Submission{Problem: "lambdaman2", Answer: "RRR"})
+	assert.Error(t, err)
+	_, err = c.Submit(ctx, Submission{Problem: "lambdaman3", Answer: "tab\t"})
+	assert.Error(t, err)
+
+	// Both valid answers survive a restart and go out once the server is up.
+	q, err = OpenQueue(path)
+	assert.NoError(t, err)
+	items := q.Items()
+	assert.Len(t, items, 2)
+	assert.Equal(t, QueuePending, items[0].State)
+	assert.Equal(t, 1, items[0].Attempts)
+	assert.Contains(t, items[0].Error, "503")
+	assert.Equal(t, "test", items[0].Solver)
+
+	up = true
+	c.Queue = q
+	n, err := q.Drain(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	items = q.Items()
+	assert.Equal(t, QueueAccepted, items[0].State)
+	assert.Equal(t, 12, items[0].ServerScore)
+	assert.Empty(t, items[0].Error)
+	assert.Equal(t, QueueRejected, items[1].State)
+	assert.Equal(t, "Your solution for lambdaman2 is incorrect", items[1].Response)
+	assert.Empty(t, q.Pending())
+
+	res, err := c.Submit(ctx, Submission{Problem: "lambdaman4", Answer: "LLL"})
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.Len(t, q.Items(), 3)
+
+	// Finished submissions are dropped when the queue is next opened.
+	reopened, err := OpenQueue(path)
+	assert.NoError(t, err)
+	assert.Empty(t, reopened.Items())
+	id, err := reopened.Add(Submission{Problem: "lambdaman5", Answer: "LLL"})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, id)
+
+	// Both queues change the same file, as processes sharing it would.
+	id, err = q.Add(Submission{Problem: "lambdaman6", Answer: "LLL"})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, id)
+	assert.Len(t, q.Items(), 2)
+	assert.NoError(t, reopened.update(4, func(item *QueueItem) error {
+		item.State = QueueRejected
+		return nil
+	}))
+	assert.NoError(t, q.Clean())
+	assert.Len(t, q.Items(), 1)
+	assert.NoError(t, reopened.update(5, func(item *QueueItem) error {
+		item.State = QueueAccepted
+		return nil
+	}))
+	assert.NoError(t, q.Clean())
+	assert.Empty(t, q.Items())
+	byts, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"next_id\": 6,\n  \"items\": []\n}\n", string(byts))
+}
+
+func TestQueueFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byts, _ := io.ReadAll(r.Body)
+		switch fields := strings.Fields(DecodeRequest(string(byts))); fields[1] {
+		case "lambdaman1":
+			http.Error(w, "no such problem", http.StatusNotFound)
+		case "lambdaman2":
+			http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+		default:
+			io.WriteString(w, string(StringToToken("Correct, you solved "+fields[1]+" with a score of 30!")))
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "queue.json")
+	q, err := OpenQueue(path)
+	assert.NoError(t, err)
+	l, err := OpenLedger(filepath.Join(t.TempDir(), "solutions.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, l.Record(Submission{Problem: "lambdaman3", Answer: "L", LocalScore: 10, Success: true}))
+	c := &Client{BaseURL: server.URL, Token: "test", Queue: q, Ledger: l}
+	ctx := context.Background()
+
+	// A 4xx won't get better by retrying.
+	_, err = c.Submit(ctx, Submission{Problem: "lambdaman1", Answer: "LLL"})
+	assert.Error(t, err)
+	assert.Equal(t, QueueFailed, q.Items()[0].State)
+
+	// An answer the ledger skips was never sent, so isn't accepted.
+	res, err := c.Submit(ctx, Submission{Problem: "lambdaman3", Answer: "LLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLLL"})
+	assert.NoError(t, err)
+	assert.True(t, res.Skipped)
+	assert.Equal(t, QueueSkipped, q.Items()[1].State)
+	assert.Empty(t, q.Items()[1].Response)
+
+	// No reply leaves a submission pending, until it runs out of attempts.
+	_, err = c.Submit(ctx, Submission{Problem: "lambdaman2", Answer: "LLL"})
+	assert.Error(t, err)
+	for i := 1; i < maxQueueAttempts; i++ {
+		assert.Equal(t, QueuePending, q.Items()[2].State)
+		q.Drain(ctx, c)
+	}
+	assert.Equal(t, QueueFailed, q.Items()[2].State)
+	assert.Equal(t, maxQueueAttempts, q.Items()[2].Attempts)
+
+	// Failed submissions don't stop a drain.
+	_, err = q.Add(Submission{Problem: "lambdaman4", Answer: "LLL"})
+	assert.NoError(t, err)
+	n, err := q.Retry()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	q.update(3, func(item *QueueItem) error {
+		item.Attempts = maxQueueAttempts - 1
+		return nil
+	})
+	n, err = q.Drain(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	q, err = OpenQueue(path)
+	assert.NoError(t, err)
+	var states []QueueState
+	for _, item := range q.Items() {
+		states = append(states, item.State)
+	}
+	assert.Equal(t, []QueueState{QueueFailed, QueueFailed}, states)
+}
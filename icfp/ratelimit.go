@@ -0,0 +1,118 @@
+package icfp
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimiter is a token bucket that is safe to share between goroutines.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows perMinute requests per minute on average, with bursts
+// of up to burst requests.
+func NewRateLimiter(perMinute float64, burst int) *RateLimiter {
+	return &RateLimiter{rate: perMinute / 60, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until a request may be made and returns how long it waited.
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait == 0 {
+		return 0, nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return wait, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+// delay is exponential backoff with jitter: a random duration between half
+// and all of BaseDelay*2^attempt, capped at MaxDelay.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay << attempt
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Body)
+}
+
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == 429 || e.StatusCode >= 500
+}
+
+type Metrics struct {
+	Requests      atomic.Int64
+	Retries       atomic.Int64
+	Failures      atomic.Int64
+	RateLimitWait atomic.Int64
+	BackoffWait   atomic.Int64
+	CacheHits     atomic.Int64
+}
+
+func (m *Metrics) String() string {
+	return fmt.Sprintf("%d requests, %d cache hits, %d retries, %d failures, waited %s for rate limits and %s backing off",
+		m.Requests.Load(), m.CacheHits.Load(), m.Retries.Load(), m.Failures.Load(),
+		time.Duration(m.RateLimitWait.Load()), time.Duration(m.BackoffWait.Load()))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,224 @@
+package icfp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type RecordMode int
+
+const (
+	// Replay answers requests from the cassette and never touches the network.
+	Replay RecordMode = iota
+	// Record forwards requests and appends the exchanges to the cassette.
+	Record
+)
+
+// RecordModeFromEnv is Record when $ICFP_RECORD is set, for refreshing
+// cassettes against the real server, and Replay otherwise.
+func RecordModeFromEnv() RecordMode {
+	if os.Getenv("ICFP_RECORD") != "" {
+		return Record
+	}
+	return Replay
+}
+
+// Interaction is one recorded request and response. Command is the decoded
+// request, which is what replays match on.
+type Interaction struct {
+	Command  string `json:"command"`
+	Request  string `json:"request"`
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records exchanges with the
+// /communicate endpoint to a cassette file, or replays them from it.
+type Recorder struct {
+	Path string
+	Mode RecordMode
+	// Transport is used in Record mode; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder loads the cassette at path. A missing cassette is an error
+// when replaying and starts an empty one when recording.
+func NewRecorder(path string, mode RecordMode) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	byts, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && mode == Record {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(byts, &r.cassette); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if mode == Record {
+		// Re-recording replaces the cassette rather than appending to it.
+		r.cassette.Interactions = nil
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// DecodeRequest evaluates an encoded request to the command it sends, or
+// returns body unchanged if it doesn't evaluate to a string.
+func DecodeRequest(body string) string {
+	tokens, err := parseSafe(strings.TrimSpace(body))
+	if err != nil {
+		return body
+	}
+	expr, rest := combinePartial(tokens)
+	if len(rest) > 0 {
+		return body
+	}
+	ev := &Evaluator{MaxSteps: 1000000}
+	res, err := ev.Eval(expr, nil)
+	if s, ok := res.(String); ok && err == nil {
+		return string(s)
+	}
+	return body
+}
+
+func parseSafe(s string) (exprs []Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return Parse(s), nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	command := DecodeRequest(string(body))
+	if r.Mode == Replay {
+		in, ok := r.next(command)
+		if !ok {
+			return nil, fmt.Errorf("%s: no recorded response for %q", r.Path, command)
+		}
+		return response(req, in.Status, in.Response), nil
+	}
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Command: command, Request: string(body), Status: resp.StatusCode, Response: string(respBody),
+	})
+	r.used = append(r.used, true)
+	r.mu.Unlock()
+	if err := r.Save(); err != nil {
+		return nil, err
+	}
+	return response(req, resp.StatusCode, string(respBody)), nil
+}
+
+// next returns the first unused interaction for command, so repeated
+// commands replay in recorded order, then keeps returning the last one.
+func (r *Recorder) next(command string) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last := -1
+	for i, in := range r.cassette.Interactions {
+		if in.Command != command {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return in, true
+		}
+		last = i
+	}
+	if last < 0 {
+		return Interaction{}, false
+	}
+	return r.cassette.Interactions[last], true
+}
+
+func response(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Save writes the cassette to Path.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	byts, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, append(byts, '\n'), 0644)
+}
+
+// NewRecordingClient returns a client whose requests go through a Recorder
+// for the cassette at path, in the mode given by RecordModeFromEnv. Replaying
+// needs no token; recording uses the token and URL from NewClient.
+func NewRecordingClient(path string) (*Client, error) {
+	mode := RecordModeFromEnv()
+	rec, err := NewRecorder(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{BaseURL: DefaultBaseURL, Token: "replay"}
+	if mode == Record {
+		if c, err = NewClient(); err != nil {
+			return nil, err
+		}
+		c.Cache = nil
+	}
+	c.HTTPClient = &http.Client{Transport: rec}
+	return c, nil
+}
@@ -0,0 +1,57 @@
+package icfp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, string(StringToToken(DecodeRequest(string(body))+" back")))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	rec, err := NewRecorder(path, Record)
+	assert.NoError(t, err)
+	c := &Client{BaseURL: server.URL, Token: "secret", HTTPClient: &http.Client{Transport: rec}}
+	s, err := c.CommunicateString(context.Background(), "echo one")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo one back", s)
+	// A computed request is recorded under the command it evaluates to,
+	// "echo " . "one".
+	_, err = c.CommunicateToken(context.Background(), `B. S%#(/} S/.%`)
+	assert.NoError(t, err)
+	_, err = c.CommunicateString(context.Background(), "echo one")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	byts, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(byts), `"command": "echo one"`)
+
+	rec, err = NewRecorder(path, Replay)
+	assert.NoError(t, err)
+	c = &Client{BaseURL: "http://unused.invalid", Token: "secret", HTTPClient: &http.Client{Transport: rec}}
+	for i := 0; i < 4; i++ {
+		s, err = c.CommunicateString(context.Background(), "echo one")
+		assert.NoError(t, err)
+		assert.Equal(t, "echo one back", s)
+	}
+	assert.Equal(t, 3, calls)
+	_, err = c.CommunicateString(context.Background(), "echo two")
+	assert.ErrorContains(t, err, `no recorded response for "echo two"`)
+
+	_, err = NewRecorder(filepath.Join(t.TempDir(), "missing.json"), Replay)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package icfp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EvalBudget bounds the evaluation of a server response. Zero fields take
+// their value from DefaultEvalBudget. MaxValueBytes limits the size of each
+// value and MaxMemory the environments and thunks made; see Evaluator.
+type EvalBudget struct {
+	MaxSteps      int64
+	MaxDepth      int
+	Timeout       time.Duration
+	MaxValueBytes int64
+	MaxMemory     int64
+}
+
+var DefaultEvalBudget = EvalBudget{
+	MaxSteps:      100000000,
+	MaxDepth:      200000,
+	Timeout:       30 * time.Second,
+	MaxValueBytes: 64 << 20,
+	MaxMemory:     256 << 20,
+}
+
+func (b EvalBudget) withDefaults() EvalBudget {
+	if b.MaxSteps == 0 {
+		b.MaxSteps = DefaultEvalBudget.MaxSteps
+	}
+	if b.MaxDepth == 0 {
+		b.MaxDepth = DefaultEvalBudget.MaxDepth
+	}
+	if b.Timeout == 0 {
+		b.Timeout = DefaultEvalBudget.Timeout
+	}
+	if b.MaxValueBytes == 0 {
+		b.MaxValueBytes = DefaultEvalBudget.MaxValueBytes
+	}
+	if b.MaxMemory == 0 {
+		b.MaxMemory = DefaultEvalBudget.MaxMemory
+	}
+	return b
+}
+
+// Response is a server reply: the ICFP program that was sent back and the
+// text it evaluated to. Raw is empty for replies served from the cache.
+type Response struct {
+	Raw    string
+	Text   string
+	Stats  Stats
+	Cached bool
+}
+
+// ResponseError is a reply that couldn't be decoded to text.
+type ResponseError struct {
+	Raw string
+	// Result is the rendered value when the reply evaluated to something
+	// other than a string.
+	Result string
+	Err    error
+}
+
+func (e *ResponseError) Error() string {
+	return "bad response: " + e.Err.Error()
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeResponse parses and evaluates a reply within budget.
+func DecodeResponse(raw string, budget EvalBudget) (*Response, error) {
+	tokens, err := parseSafe(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, &ResponseError{Raw: raw, Err: err}
+	}
+	expr, rest := combinePartial(tokens)
+	if len(rest) > 0 {
+		return nil, &ResponseError{Raw: raw, Err: fmt.Errorf("%d unused tokens", len(rest))}
+	}
+	budget = budget.withDefaults()
+	ev := &Evaluator{MaxSteps: budget.MaxSteps, MaxDepth: budget.MaxDepth, Timeout: budget.Timeout, MaxValueBytes: budget.MaxValueBytes, MaxMemory: budget.MaxMemory}
+	res, err := ev.Eval(expr, nil)
+	if err != nil {
+		return nil, &ResponseError{Raw: raw, Err: err}
+	}
+	str, ok := res.(String)
+	if !ok {
+		rendered := RenderAsLambda(res)
+		return nil, &ResponseError{Raw: raw, Result: rendered, Err: fmt.Errorf("expected a string, got %s", truncate(rendered, 60))}
+	}
+	return &Response{Raw: raw, Text: string(str), Stats: ev.Stats}, nil
+}
@@ -0,0 +1,83 @@
+package icfp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	resp, err := DecodeResponse(`B. S%#(/} S/.%`, EvalBudget{})
+	assert.NoError(t, err)
+	assert.Equal(t, "echo one", resp.Text)
+	assert.Equal(t, `B. S%#(/} S/.%`, resp.Raw)
+	assert.Equal(t, int64(3), resp.Stats.Steps)
+
+	_, err = DecodeResponse(`I"`, EvalBudget{})
+	var respErr *ResponseError
+	assert.True(t, errors.As(err, &respErr))
+	assert.Equal(t, "1", respErr.Result)
+	assert.EqualError(t, err, "bad response: expected a string, got 1")
+
+	_, err = DecodeResponse(`X`, EvalBudget{})
+	assert.ErrorContains(t, err, "bad response: ")
+	_, err = DecodeResponse(`S# S#`, EvalBudget{})
+	assert.EqualError(t, err, "bad response: 1 unused tokens")
+
+	omega := `B$ L! B$ v! v! L! B$ v! v!`
+	_, err = DecodeResponse(omega, EvalBudget{MaxSteps: 1000})
+	var budgetErr *BudgetError
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.EqualError(t, err, "bad response: exceeded step budget of 1000")
+
+	_, err = DecodeResponse(omega, EvalBudget{Timeout: time.Millisecond})
+	assert.EqualError(t, err, "bad response: exceeded time budget of 1ms")
+
+	// (λd.d (d (d "abcd"))) (λx.. x x) builds a 32 byte string.
+	doubling, err := ParseLambda(`(λd.(d (d (d "abcd")))) (λx.(. x x))`)
+	assert.NoError(t, err)
+	resp, err = DecodeResponse(Encode(doubling), EvalBudget{MaxValueBytes: 32})
+	assert.NoError(t, err)
+	assert.Len(t, resp.Text, 32)
+	_, err = DecodeResponse(Encode(doubling), EvalBudget{MaxValueBytes: 31})
+	assert.EqualError(t, err, "bad response: exceeded memory budget of 31")
+
+	// Nesting lambdas grows the environment each one copies, so a few
+	// thousand steps allocate tens of megabytes.
+	var nested Expr = String("done")
+	for i := int64(2000); i > 0; i-- {
+		nested = Binop{"$", Lambda{Param: i, Body: nested}, Integer{Int: big.NewInt(i)}}
+	}
+	ev := &Evaluator{}
+	_, err = ev.Eval(nested, nil)
+	assert.NoError(t, err)
+	assert.Less(t, ev.Stats.Steps, int64(10000))
+	assert.Greater(t, ev.Stats.Memory, int64(32<<20))
+	_, err = DecodeResponse(Encode(nested), EvalBudget{MaxMemory: 1 << 20})
+	assert.EqualError(t, err, "bad response: exceeded memory budget of 1048576")
+}
+
+func TestClientCommunicate(t *testing.T) {
+	reply := `S%#(/`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, reply)
+	}))
+	defer server.Close()
+	c := &Client{BaseURL: server.URL, Token: "secret", HTTPClient: server.Client()}
+
+	resp, err := c.Communicate(context.Background(), "echo")
+	assert.NoError(t, err)
+	assert.Equal(t, &Response{Raw: `S%#(/`, Text: "echo", Stats: Stats{Steps: 1, MaxDepth: 1}}, resp)
+
+	reply = `B$ L! B$ v! v! L! B$ v! v!`
+	c.Budget = EvalBudget{MaxSteps: 100}
+	_, err = c.Communicate(context.Background(), "echo")
+	assert.EqualError(t, err, "bad response: exceeded step budget of 100")
+}
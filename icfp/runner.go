@@ -0,0 +1,284 @@
+package icfp
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Solver solves the problems of one course. localScore is in the units of the
+// server's score, such as the number of moves for spaceship; zero means
+// DefaultLocalScore, which for lambdaman is the size of the request.
+type Solver interface {
+	Name() string
+	Course() string
+	Solve(ctx context.Context, input string) (answer string, localScore int, err error)
+}
+
+// ProblemSet is a set of problem numbers such as 3,7-12. The empty set
+// contains every problem.
+type ProblemSet [][2]int
+
+func ParseProblemSet(s string) (ProblemSet, error) {
+	var set ProblemSet
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("bad problem number %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(hi); err != nil || to < from {
+				return nil, fmt.Errorf("bad problem range %q", part)
+			}
+		}
+		set = append(set, [2]int{from, to})
+	}
+	return set, nil
+}
+
+func (set ProblemSet) Contains(n int) bool {
+	if len(set) == 0 {
+		return true
+	}
+	for _, r := range set {
+		if n >= r[0] && n <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+type RunOptions struct {
+	Problems ProblemSet
+	// Workers is how many problems are solved at once; zero means 4.
+	Workers int
+	// Timeout bounds each call to Solve; zero means no limit.
+	Timeout time.Duration
+}
+
+type RunResult struct {
+	Problem    Problem
+	Solver     string
+	Answer     string
+	LocalScore int
+	// Result is nil if the problem wasn't submitted because of Err.
+	Result   *SolveResult
+	Err      error
+	Duration time.Duration
+}
+
+func (r RunResult) Status() string {
+	switch {
+	case errors.Is(r.Err, context.DeadlineExceeded):
+		return "timeout"
+	case r.Err != nil:
+		return "error"
+	case r.Result.Skipped:
+		return "skipped"
+	case r.Result.Success:
+		return "solved"
+	}
+	return "rejected"
+}
+
+// Run solves and submits the selected problems of s's course, returning the
+// results in problem order.
+func (c *Client) Run(ctx context.Context, s Solver, opts RunOptions) ([]RunResult, error) {
+	problems, err := c.Problems(ctx, s.Course())
+	if err != nil {
+		return nil, err
+	}
+	var selected []Problem
+	for _, p := range problems {
+		if opts.Problems.Contains(p.Number) {
+			selected = append(selected, p)
+		}
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+
+	results := make([]RunResult, len(selected))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.runOne(ctx, s, selected[i], opts.Timeout)
+			}
+		}()
+	}
+	for i := range selected {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
+
+func (c *Client) runOne(ctx context.Context, s Solver, p Problem, timeout time.Duration) RunResult {
+	res := RunResult{Problem: p, Solver: s.Name()}
+	l := Logger(ctx).With("course", s.Course(), "problem", p.ID, "solver", s.Name())
+	ctx = WithLogger(ctx, l)
+	start := time.Now()
+	defer func() {
+		res.Duration = time.Since(start)
+		attrs := []any{"status", res.Status(), "bytes", len(res.Answer), "local_score", res.LocalScore, "duration", res.Duration}
+		switch {
+		case res.Err != nil:
+			l.Error("problem failed", append(attrs, "err", res.Err)...)
+		case res.Result.Success:
+			l.Info("problem done", append(attrs, "score", res.Result.Score, "best", p.BestScore)...)
+		default:
+			l.Warn("problem rejected", append(attrs, "reply", res.Result.Error)...)
+		}
+	}()
+
+	input, err := c.Get(ctx, p.ID)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	l.Debug("solving", "input_bytes", len(input))
+	res.Answer, res.LocalScore, res.Err = solveWithin(ctx, s, input, timeout)
+	if res.Err != nil {
+		return res
+	}
+	res.Result, res.Err = c.Submit(ctx, Submission{Problem: p.ID, Answer: res.Answer, Solver: s.Name(), LocalScore: res.LocalScore})
+	return res
+}
+
+// solveWithin gives up on s after timeout, even if it ignores ctx.
+func solveWithin(ctx context.Context, s Solver, input string, timeout time.Duration) (string, int, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	type result struct {
+		answer string
+		score  int
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		answer, score, err := s.Solve(ctx, input)
+		done <- result{answer, score, err}
+	}()
+	select {
+	case r := <-done:
+		return r.answer, r.score, r.err
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+}
+
+// WriteSummary prints results as a table, with totals for each status.
+func WriteSummary(w io.Writer, results []RunResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "problem\tsolver\tstatus\tlocal\tscore\tbest\ttime\t")
+	counts := map[string]int{}
+	for _, r := range results {
+		status := r.Status()
+		counts[status]++
+		score := "-"
+		if r.Result != nil && r.Result.Score > 0 {
+			score = strconv.Itoa(r.Result.Score)
+		}
+		best := "-"
+		if r.Problem.BestScore > 0 {
+			best = strconv.Itoa(r.Problem.BestScore)
+		}
+		if r.Err != nil {
+			status += ": " + truncate(r.Err.Error(), 40)
+		} else if r.Result != nil && !r.Result.Success {
+			status += ": " + truncate(r.Result.Error, 40)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t\n", r.Problem.ID, r.Solver, status, r.LocalScore, score, best, r.Duration.Round(time.Millisecond))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	var statuses []string
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var totals []string
+	for _, status := range statuses {
+		totals = append(totals, fmt.Sprintf("%d %s", counts[status], status))
+	}
+	_, err := fmt.Fprintf(w, "%d problems: %s\n", len(results), strings.Join(totals, ", "))
+	return err
+}
+
+// SolverMain is the main function of a solver harness: it runs s over the
+// problems given by the -problems flag with the default client, and prints
+// a summary.
+func SolverMain(s Solver) {
+	problems := flag.String("problems", "", "problems to solve, such as 3,7-12 (default all)")
+	workers := flag.Int("workers", 4, "problems to solve at once")
+	timeout := flag.Duration("timeout", time.Minute, "time limit for solving each problem (0 for none)")
+	logFormat := flag.String("log-format", "", "log format: text or json (default $ICFP_LOG_FORMAT or text)")
+	logLevel := flag.String("log-level", "", "log level: debug, info, warn or error (default $ICFP_LOG_LEVEL or info)")
+	flag.Parse()
+
+	err := func() error {
+		if err := SetupLogging(*logFormat, *logLevel); err != nil {
+			return err
+		}
+		set, err := ParseProblemSet(*problems)
+		if err != nil {
+			return err
+		}
+		c, err := DefaultClient()
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		if c.Queue != nil {
+			// Send what an earlier run solved but didn't get to submit. If
+			// that fails, they wait for the next run rather than stopping
+			// this one.
+			n, err := c.Queue.Drain(ctx, c)
+			if err != nil {
+				slog.Warn("resuming queued submissions failed", "sent", n, "err", err)
+			} else if n > 0 {
+				slog.Info("resumed queued submissions", "sent", n)
+			}
+		}
+		results, err := c.Run(ctx, s, RunOptions{Problems: set, Workers: *workers, Timeout: *timeout})
+		if err != nil {
+			return err
+		}
+		return WriteSummary(os.Stdout, results)
+	}()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package icfp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProblemSet(t *testing.T) {
+	set, err := ParseProblemSet("3, 7-12")
+	assert.NoError(t, err)
+	assert.Equal(t, ProblemSet{{3, 3}, {7, 12}}, set)
+	assert.True(t, set.Contains(3))
+	assert.True(t, set.Contains(12))
+	assert.False(t, set.Contains(4))
+	assert.False(t, set.Contains(13))
+
+	set, err = ParseProblemSet("")
+	assert.NoError(t, err)
+	assert.True(t, set.Contains(42))
+
+	_, err = ParseProblemSet("3-x")
+	assert.EqualError(t, err, `bad problem range "3-x"`)
+	_, err = ParseProblemSet("5-2")
+	assert.Error(t, err)
+	_, err = ParseProblemSet("three")
+	assert.EqualError(t, err, `bad problem number "three"`)
+}
+
+type testSolver struct{}
+
+func (testSolver) Name() string   { return "test/reverse" }
+func (testSolver) Course() string { return "test" }
+
+func (testSolver) Solve(ctx context.Context, input string) (string, int, error) {
+	switch input {
+	case "slow":
+		<-ctx.Done()
+		return "", 0, ctx.Err()
+	case "stuck":
+		time.Sleep(time.Second)
+	case "bad":
+		return "", 0, errors.New("can't solve")
+	}
+	var sb strings.Builder
+	for i := len(input) - 1; i >= 0; i-- {
+		sb.WriteByte(input[i])
+	}
+	return sb.String(), len(input), nil
+}
+
+func TestClientRun(t *testing.T) {
+	inputs := map[string]string{"test1": "abc", "test2": "slow", "test3": "bad", "test4": "xyz", "test5": "stuck", "test6": "abc"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byts, _ := io.ReadAll(r.Body)
+		verb, args, _ := strings.Cut(DecodeRequest(string(byts)), " ")
+		reply := ""
+		switch {
+		case verb == "get" && args == "test":
+			reply = "Test\n\n* [test1] Best score: 3.\n* [test2]\n* [test3]\n* [test4]\n* [test5]\n* [test6]\n"
+		case verb == "get":
+			reply = inputs[args]
+		case verb == "solve" && strings.HasSuffix(args, " cba"):
+			reply = "Correct, you solved " + strings.Fields(args)[0] + " with a score of 3!"
+		default:
+			reply = "Your solution for " + strings.Fields(args)[0] + " is incorrect"
+		}
+		io.WriteString(w, string(StringToToken(reply)))
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL, Token: "test"}
+	set, err := ParseProblemSet("1-5")
+	assert.NoError(t, err)
+	results, err := c.Run(context.Background(), testSolver{}, RunOptions{Problems: set, Workers: 2, Timeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+
+	var statuses []string
+	for _, r := range results {
+		statuses = append(statuses, r.Problem.ID+" "+r.Status())
+	}
+	assert.Equal(t, []string{"test1 solved", "test2 timeout", "test3 error", "test4 rejected", "test5 timeout"}, statuses)
+	assert.Equal(t, "cba", results[0].Answer)
+	assert.Equal(t, 3, results[0].LocalScore)
+	assert.Equal(t, "test/reverse", results[0].Solver)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSummary(&buf, results))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Regexp(t, `^problem\s+solver\s+status\s+local\s+score\s+best\s+time`, lines[0])
+	assert.Regexp(t, `^test1\s+test/reverse\s+solved\s+3\s+3\s+3\s`, lines[1])
+	assert.Contains(t, lines[3], "error: can't solve")
+	assert.Equal(t, "5 problems: 1 error, 1 rejected, 1 solved, 2 timeout", lines[6])
+}
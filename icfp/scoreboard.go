@@ -0,0 +1,172 @@
+package icfp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scoreboard is the markdown table served by `get scoreboard` (Course is
+// empty) or `get scoreboard <course>`.
+type Scoreboard struct {
+	Course  string    `json:"course"`
+	Fetched time.Time `json:"fetched"`
+	// Columns are the score columns: courses overall, problems per course.
+	Columns []string   `json:"columns"`
+	Rows    []ScoreRow `json:"rows"`
+}
+
+type ScoreRow struct {
+	Rank   int               `json:"rank"`
+	Team   string            `json:"team"`
+	Scores map[string]string `json:"scores"`
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// ParseScoreboard parses the first markdown table in s, whose header must
+// have a "#" and a "team" column.
+func ParseScoreboard(course, s string) (*Scoreboard, error) {
+	sb := &Scoreboard{Course: course}
+	var header []string
+	rank, team := -1, -1
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "|") {
+			if header != nil {
+				break
+			}
+			continue
+		}
+		cells := splitRow(line)
+		if header == nil {
+			header = cells
+			for i, c := range cells {
+				switch strings.ToLower(c) {
+				case "#":
+					rank = i
+				case "team":
+					team = i
+				default:
+					sb.Columns = append(sb.Columns, c)
+				}
+			}
+			if rank < 0 || team < 0 {
+				return nil, fmt.Errorf("scoreboard header has no # and team columns: %s", line)
+			}
+			continue
+		}
+		if strings.Trim(strings.Join(cells, ""), "-: ") == "" {
+			continue
+		}
+		if len(cells) != len(header) {
+			return nil, fmt.Errorf("scoreboard row has %d cells, expected %d: %s", len(cells), len(header), line)
+		}
+		row := ScoreRow{Team: cells[team], Scores: map[string]string{}}
+		row.Rank, _ = strconv.Atoi(cells[rank])
+		for i, c := range cells {
+			if i != rank && i != team {
+				row.Scores[header[i]] = c
+			}
+		}
+		sb.Rows = append(sb.Rows, row)
+	}
+	if header == nil {
+		return nil, fmt.Errorf("no scoreboard table found")
+	}
+	return sb, nil
+}
+
+func (sb *Scoreboard) Row(team string) (ScoreRow, bool) {
+	for _, r := range sb.Rows {
+		if r.Team == team {
+			return r, true
+		}
+	}
+	return ScoreRow{}, false
+}
+
+type ScoreChange struct {
+	Team   string
+	Column string
+	Old    string
+	New    string
+}
+
+type ScoreboardDiff struct {
+	// OldRank and NewRank are team's rank in each snapshot, zero if absent.
+	OldRank int
+	NewRank int
+	// Joined and Left are the teams only in the new or the old snapshot.
+	Joined  []string
+	Left    []string
+	Changes []ScoreChange
+}
+
+// DiffScoreboards lists every score that changed between two snapshots,
+// including those of teams that joined or left the board, along with how
+// team's rank moved.
+func DiffScoreboards(old, new *Scoreboard, team string) *ScoreboardDiff {
+	d := &ScoreboardDiff{}
+	if r, ok := old.Row(team); ok {
+		d.OldRank = r.Rank
+	}
+	if r, ok := new.Row(team); ok {
+		d.NewRank = r.Rank
+	}
+	for _, r := range new.Rows {
+		prev, ok := old.Row(r.Team)
+		if !ok {
+			d.Joined = append(d.Joined, r.Team)
+		}
+		for _, col := range new.Columns {
+			if prev.Scores[col] != r.Scores[col] {
+				d.Changes = append(d.Changes, ScoreChange{Team: r.Team, Column: col, Old: prev.Scores[col], New: r.Scores[col]})
+			}
+		}
+	}
+	for _, r := range old.Rows {
+		if _, ok := new.Row(r.Team); ok {
+			continue
+		}
+		d.Left = append(d.Left, r.Team)
+		for _, col := range old.Columns {
+			if r.Scores[col] != "" {
+				d.Changes = append(d.Changes, ScoreChange{Team: r.Team, Column: col, Old: r.Scores[col]})
+			}
+		}
+	}
+	sort.SliceStable(d.Changes, func(i, j int) bool {
+		return d.Changes[i].Column < d.Changes[j].Column
+	})
+	return d
+}
+
+// Scoreboard fetches and parses the overall scoreboard, or a course's
+// scoreboard, bypassing any cached copy.
+func (c *Client) Scoreboard(ctx context.Context, course string) (*Scoreboard, error) {
+	page := "scoreboard"
+	if course != "" {
+		page += " " + course
+	}
+	s, err := c.Get(WithCacheMode(ctx, CacheBypass), page)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := ParseScoreboard(course, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", page, err)
+	}
+	sb.Fetched = time.Now().UTC()
+	return sb, nil
+}
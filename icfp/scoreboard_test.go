@@ -0,0 +1,64 @@
+package icfp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const scoreboardPage = `The current scoreboard is shown below.
+
+| # | team | hello | lambdaman | spaceship |
+| --- | --- | --- | --- | --- |
+| 1 | Unagi | 1 | 21 | 25 |
+| 2 | Cup<T> | 1 | 15 | 22 |
+| 3 | Others |  | 3 | 4 |
+
+Scores are updated every few minutes.`
+
+func TestParseScoreboard(t *testing.T) {
+	sb, err := ParseScoreboard("", scoreboardPage)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hello", "lambdaman", "spaceship"}, sb.Columns)
+	assert.Len(t, sb.Rows, 3)
+	assert.Equal(t, ScoreRow{Rank: 2, Team: "Cup<T>", Scores: map[string]string{"hello": "1", "lambdaman": "15", "spaceship": "22"}}, sb.Rows[1])
+	assert.Equal(t, "", sb.Rows[2].Scores["hello"])
+
+	_, err = ParseScoreboard("", "no table here")
+	assert.EqualError(t, err, "no scoreboard table found")
+	_, err = ParseScoreboard("", "| a | b |\n| 1 | 2 |")
+	assert.ErrorContains(t, err, "no # and team columns")
+	_, err = ParseScoreboard("", "| # | team |\n| 1 |")
+	assert.ErrorContains(t, err, "row has 1 cells, expected 2")
+}
+
+func TestDiffScoreboards(t *testing.T) {
+	old, err := ParseScoreboard("", scoreboardPage)
+	assert.NoError(t, err)
+	new, err := ParseScoreboard("", `| # | team | hello | lambdaman | spaceship |
+| --- | --- | --- | --- | --- |
+| 1 | Cup<T> | 1 | 22 | 22 |
+| 2 | Unagi | 1 | 21 | 25 |
+| 3 | Others | 1 | 3 | 4 |
+| 4 | Newcomers | | | 1 |`)
+	assert.NoError(t, err)
+
+	d := DiffScoreboards(old, new, "Cup<T>")
+	assert.Equal(t, 2, d.OldRank)
+	assert.Equal(t, 1, d.NewRank)
+	assert.Equal(t, []ScoreChange{
+		{Team: "Others", Column: "hello", Old: "", New: "1"},
+		{Team: "Cup<T>", Column: "lambdaman", Old: "15", New: "22"},
+		{Team: "Newcomers", Column: "spaceship", Old: "", New: "1"},
+	}, d.Changes)
+	assert.Equal(t, []string{"Newcomers"}, d.Joined)
+	assert.Empty(t, d.Left)
+
+	// Teams that drop off the board are reported too.
+	d = DiffScoreboards(new, old, "Newcomers")
+	assert.Equal(t, 4, d.OldRank)
+	assert.Equal(t, 0, d.NewRank)
+	assert.Empty(t, d.Joined)
+	assert.Equal(t, []string{"Newcomers"}, d.Left)
+	assert.Contains(t, d.Changes, ScoreChange{Team: "Newcomers", Column: "spaceship", Old: "1", New: ""})
+}
@@ -0,0 +1,74 @@
+package icfp
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// CharError is a character that ICFP strings can't hold.
+type CharError struct {
+	Char rune
+	// Offset is in bytes; Line and Column count from 1, Column in runes.
+	Offset       int
+	Line, Column int
+}
+
+func (e CharError) Error() string {
+	return fmt.Sprintf("unsupported character %q (U+%04X) at line %d, column %d", e.Char, e.Char, e.Line, e.Column)
+}
+
+// TextError lists every unsupported character in a text.
+type TextError struct {
+	Chars []CharError
+}
+
+func (e *TextError) Error() string {
+	msg := e.Chars[0].Error()
+	if len(e.Chars) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e.Chars)-1)
+	}
+	return msg
+}
+
+// ValidateText checks that s only uses the 94 characters of lookup,
+// returning a *TextError if it doesn't.
+func ValidateText(s string) error {
+	var chars []CharError
+	line, col := 1, 0
+	for i, c := range s {
+		col++
+		if c > 127 || lookupIndex[c] < 0 {
+			chars = append(chars, CharError{Char: c, Offset: i, Line: line, Column: col})
+		}
+		if c == '\n' {
+			line, col = line+1, 0
+		}
+	}
+	if chars != nil {
+		return &TextError{Chars: chars}
+	}
+	return nil
+}
+
+// LocalEcho does what the echo service does with s, without its note about
+// scoring points: it returns the string token for s and the text that
+// token decodes to.
+func LocalEcho(s string) (token, text string, err error) {
+	body, err := EncodeString(s)
+	if err != nil {
+		return "", "", err
+	}
+	text, err = DecodeString(body)
+	return "S" + body, text, err
+}
+
+// Base94 returns the digits of n in base 94, most significant first, and
+// its integer token, which is U- applied to an I token for negative n.
+func Base94(n *big.Int) ([]int, string) {
+	s, _ := EncodeInt(new(big.Int).Abs(n))
+	var digits []int
+	for i := 0; i < len(s); i++ {
+		digits = append(digits, int(s[i]-firstDigit))
+	}
+	return digits, Encode(Integer{n})
+}
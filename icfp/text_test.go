@@ -0,0 +1,40 @@
+package icfp
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateText(t *testing.T) {
+	assert.NoError(t, ValidateText(lookup))
+	assert.NoError(t, ValidateText(""))
+
+	err := ValidateText("ab\ncé\x00")
+	assert.EqualError(t, err, "unsupported character 'é' (U+00E9) at line 2, column 2 (and 1 more)")
+	var te *TextError
+	assert.ErrorAs(t, err, &te)
+	assert.Equal(t, []CharError{{Char: 'é', Offset: 4, Line: 2, Column: 2}, {Char: 0, Offset: 6, Line: 2, Column: 3}}, te.Chars)
+}
+
+func TestLocalEcho(t *testing.T) {
+	tok, text, err := LocalEcho("get index")
+	assert.NoError(t, err)
+	assert.Equal(t, "S'%4}).$%8", tok)
+	assert.Equal(t, "get index", text)
+
+	_, _, err = LocalEcho("tab\t")
+	assert.EqualError(t, err, `unsupported character '\t' (U+0009) at line 1, column 4`)
+}
+
+func TestBase94(t *testing.T) {
+	digits, tok := Base94(big.NewInt(1337))
+	assert.Equal(t, []int{14, 21}, digits)
+	assert.Equal(t, "I/6", tok)
+	digits, tok = Base94(big.NewInt(0))
+	assert.Equal(t, []int{0}, digits)
+	assert.Equal(t, "I!", tok)
+	_, tok = Base94(big.NewInt(-94))
+	assert.Equal(t, "U- I\"!", tok)
+}
@@ -0,0 +1,76 @@
+package icfp
+
+import (
+	"fmt"
+	"sort"
+)
+
+type EventKind int
+
+const (
+	EventBeta EventKind = iota
+	EventPrimitive
+)
+
+func (k EventKind) String() string {
+	if k == EventBeta {
+		return "beta"
+	}
+	return "primitive"
+}
+
+// Event describes a reduction the evaluator is about to perform. For beta
+// reductions Lambda and Arg hold the function and the argument thunk; for
+// primitives (including `?`) Args holds the already evaluated operands.
+type Event struct {
+	Kind   EventKind
+	Op     string
+	Expr   Expr
+	Env    Env
+	Depth  int
+	Args   []Expr
+	Lambda Lambda
+	Arg    *Thunk
+}
+
+// Tracer is called synchronously by the Evaluator before each reduction, so it
+// may block (e.g. waiting for debugger input) or panic to abort evaluation.
+type Tracer interface {
+	Trace(e Event)
+}
+
+func (t *Thunk) String() string {
+	switch {
+	case t.Evaluated:
+		return "evaluated " + RenderAsLambda(t.Value)
+	case t.ByName:
+		return "by name " + RenderAsLambda(t.Expr)
+	default:
+		return "pending " + RenderAsLambda(t.Expr)
+	}
+}
+
+// Vars returns the variables bound in env in ascending order.
+func (env Env) Vars() []int64 {
+	vars := make([]int64, 0, len(env))
+	for k := range env {
+		vars = append(vars, k)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i] < vars[j] })
+	return vars
+}
+
+func VarName(v int64) string {
+	return RenderAsLambda(Var{v: v})
+}
+
+func (e Event) String() string {
+	if e.Kind == EventBeta {
+		return fmt.Sprintf("beta %s := %s", VarName(e.Lambda.Param), RenderAsLambda(e.Arg.Expr))
+	}
+	s := fmt.Sprintf("primitive %s", e.Op)
+	for _, arg := range e.Args {
+		s += " " + RenderAsLambda(arg)
+	}
+	return s
+}
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/lukehoban/icfp2024/icfp"
 )
@@ -70,9 +69,8 @@ func FindPath(x0, y0, x1, y1 int, grid Grid) []byte {
 	}
 }
 
-func run(example string) (string, error) {
+func run(log *slog.Logger, example string) (string, error) {
 	grid := parseGrid(example)
-	fmt.Printf("%v\n", grid)
 	width := len(grid[0])
 	dots := map[int]struct{}{}
 	x := 0
@@ -88,8 +86,7 @@ func run(example string) (string, error) {
 			}
 		}
 	}
-	fmt.Printf("%v\n", dots)
-	fmt.Printf("lambdaman is at (%d, %d)\n", x, y)
+	log.Debug("parsed grid", "width", width, "height", len(grid), "pills", len(dots), "x", x, "y", y)
 	var ret []byte
 	for {
 		closest := 100000000000
@@ -110,64 +107,30 @@ func run(example string) (string, error) {
 				closestIndex = dot
 			}
 		}
-		fmt.Printf("closest dot is at (%d, %d)\n", closestX, closestY)
 		delete(dots, closestIndex)
 
 		moves := FindPath(x, y, closestX, closestY, grid)
-		fmt.Printf("moves: %s\n", string(moves))
+		log.Debug("walking to closest pill", "x", closestX, "y", closestY, "moves", string(moves))
 		x = closestX
 		y = closestY
 		ret = append(ret, moves...)
 	}
 
-	fmt.Printf("moves: %s\n", string(ret))
+	log.Debug("walked to every pill", "bytes", len(ret))
 	return string(ret), nil
 }
 
-func do() error {
-	var wg sync.WaitGroup
+// greedy solves lambdaman problems by walking to the nearest pill.
+type greedy struct{}
 
-	for i := 1; i <= 25; i++ {
-		time.Sleep(10 * time.Second)
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
+func (greedy) Name() string   { return "lambdaman/greedy" }
+func (greedy) Course() string { return "lambdaman" }
 
-			// if i < 16 {
-			// 	return
-			// }
-			fmt.Printf("Getting lambdaman%d\n", i)
-			in, err := icfp.CommunicateString(fmt.Sprintf("get lambdaman%d", i))
-			if err != nil {
-				fmt.Printf("Failed to get spaceship%d: %v\n", i, err)
-				return
-			}
-			fmt.Printf("Got back %d\n", len(string(in)))
-			s, err := run(string(in))
-			if err != nil {
-				fmt.Printf("Invalid response for lambdaman%d: %v\n", i, err)
-				return
-			}
-
-			fmt.Printf("lambdaman%d: %s\n", i, s)
-
-			answer, err := icfp.CommunicateString(fmt.Sprintf("solve lambdaman%d %s", i, s))
-			if err != nil {
-				fmt.Printf("Failed to get spaceship%d: %v\n", i, err)
-				return
-			}
-			fmt.Printf("Response: %s\n", answer)
-		}(i)
-	}
-
-	wg.Wait()
-
-	return nil
+func (greedy) Solve(ctx context.Context, input string) (string, int, error) {
+	s, err := run(icfp.Logger(ctx), input)
+	return s, 0, err
 }
 
 func main() {
-	err := do()
-	if err != nil {
-		panic(err)
-	}
+	icfp.SolverMain(greedy{})
 }
@@ -2,37 +2,18 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"os"
 
 	"github.com/lukehoban/icfp2024/icfp"
 )
 
 func main() {
-	s := ""
-	if len(os.Args) >= 2 {
-		s = os.Args[1]
-	} else {
-		b, err := io.ReadAll(os.Stdin)
-		if err != nil {
-			panic(err)
-		}
-		s = string(b)
+	err := icfp.SetupLogging("", "")
+	if err == nil {
+		err = run(os.Args[1:], os.Stdin, os.Stdout)
 	}
-	tok := icfp.StringToToken(s)
-	ret, err := icfp.CommunicateToken(string(tok))
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
 	}
-
-	expr, rest := icfp.CombineToExpr(ret)
-	if len(rest) > 0 {
-		fmt.Printf("WARNING - didn't use all input! %v\n", rest)
-	}
-
-	fmt.Printf("%v\n\n", expr)
-	fmt.Printf("%v\n\n", icfp.RenderAsLambda(expr))
-
-	res := icfp.Eval(expr, nil)
-	fmt.Printf("%v", res)
 }
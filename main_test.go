@@ -1,26 +1,27 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"testing"
 
 	"github.com/lukehoban/icfp2024/icfp"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestFirstMessage replays testdata/first_message.json; run with
+// ICFP_RECORD=1 to re-record it against the server.
 func TestFirstMessage(t *testing.T) {
+	c, err := icfp.NewRecordingClient("testdata/first_message.json")
+	assert.NoError(t, err)
+
 	s := "solve language_test 4w3s0m3"
 	tok := icfp.StringToToken(s)
-	fmt.Printf("Sending: %s\n", s)
-	ret, err := icfp.CommunicateToken(string(tok))
+	ret, err := c.CommunicateToken(context.Background(), string(tok))
 	assert.NoError(t, err)
 
 	expr, rest := icfp.CombineToExpr(ret)
-	fmt.Printf("Expr: %v\n", expr)
-	fmt.Printf("Rest: %v\n", rest)
-
 	assert.Empty(t, rest)
 
 	res := icfp.Eval(expr, nil)
-	fmt.Printf("Res: %v\n", res)
+	assert.Equal(t, icfp.String("Correct, you solved hello4!"), res)
 }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukehoban/icfp2024/icfp"
+)
+
+const replHelp = `Enter an ICFP program either as raw tokens (B$ L# v# I") or in lambda
+notation ((λx.x) 1) or JSON. Lines that parse as tokens are taken as tokens.
+
+  :let name = expr   bind name for later lambda notation input
+  :encode expr       print expr as ICFP tokens
+  :render expr       print expr in lambda notation
+  :json expr         print expr as JSON
+  :dot expr          print expr as a Graphviz graph
+  :load file         run each line of file
+  :strategy s        set application strategy (need, name, value)
+  :steps n           set the step budget (0 for unlimited)
+  :depth n           set the recursion depth budget (0 for unlimited)
+  :history           list previous inputs
+  :quit              exit
+`
+
+type repl struct {
+	out      io.Writer
+	strategy icfp.Strategy
+	maxSteps int64
+	maxDepth int
+	parser   icfp.LambdaParser
+	history  []string
+}
+
+func newRepl(out io.Writer) *repl {
+	return &repl{out: out, parser: icfp.LambdaParser{Globals: map[string]icfp.Expr{}}}
+}
+
+func parseTokens(s string) (expr icfp.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			expr, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	var exprs []icfp.Expr
+	for _, tok := range strings.Fields(s) {
+		exprs = append(exprs, icfp.ParseToken(tok))
+	}
+	expr, rest := icfp.CombineToExpr(exprs)
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("didn't use all input! %v", rest)
+	}
+	return expr, nil
+}
+
+func (r *repl) parse(s string) (icfp.Expr, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return icfp.UnmarshalExpr([]byte(s))
+	}
+	if expr, err := parseTokens(s); err == nil {
+		return expr, nil
+	}
+	return r.parser.Parse(s)
+}
+
+func (r *repl) eval(expr icfp.Expr) {
+	ev := icfp.Evaluator{Strategy: r.strategy, MaxSteps: r.maxSteps, MaxDepth: r.maxDepth}
+	start := time.Now()
+	res, err := ev.Eval(expr, nil)
+	elapsed := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+	} else {
+		fmt.Fprintf(r.out, "%s\n", icfp.RenderAsLambda(res))
+		if s, ok := res.(icfp.String); ok {
+			fmt.Fprintf(r.out, "%s\n", string(s))
+		}
+	}
+	fmt.Fprintf(r.out, "[%s: %d steps, %d beta reductions, depth %d, %s]\n",
+		r.strategy, ev.Stats.Steps, ev.Stats.BetaReductions, ev.Stats.MaxDepth, elapsed)
+}
+
+func (r *repl) load(file string) error {
+	byts, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(byts), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !r.handle(line) {
+			break
+		}
+	}
+	return nil
+}
+
+// handle runs one line of input, returning false when the session should end.
+func (r *repl) handle(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	r.history = append(r.history, line)
+	if !strings.HasPrefix(line, ":") {
+		expr, err := r.parse(line)
+		if err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+			return true
+		}
+		r.eval(expr)
+		return true
+	}
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	var err error
+	switch cmd {
+	case ":quit", ":q":
+		return false
+	case ":help", ":h":
+		fmt.Fprint(r.out, replHelp)
+	case ":let":
+		name, body, ok := strings.Cut(arg, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			err = fmt.Errorf("usage: :let name = expr")
+			break
+		}
+		var expr icfp.Expr
+		expr, err = r.parse(body)
+		if err == nil {
+			r.parser.Globals[name] = expr
+		}
+	case ":encode", ":render", ":json", ":dot":
+		var expr icfp.Expr
+		expr, err = r.parse(arg)
+		if err != nil {
+			break
+		}
+		switch cmd {
+		case ":encode":
+			fmt.Fprintf(r.out, "%s\n", icfp.Encode(expr))
+		case ":render":
+			fmt.Fprintf(r.out, "%s\n", icfp.RenderAsLambda(expr))
+		case ":json":
+			var byts []byte
+			byts, err = icfp.MarshalExpr(expr)
+			fmt.Fprintf(r.out, "%s\n", byts)
+		case ":dot":
+			err = icfp.WriteDot(r.out, expr, icfp.DotOptions{Share: true, CollapseLambdas: true})
+		}
+	case ":load":
+		err = r.load(arg)
+	case ":strategy":
+		r.strategy, err = icfp.ParseStrategy(arg)
+	case ":steps":
+		r.maxSteps, err = strconv.ParseInt(arg, 10, 64)
+	case ":depth":
+		r.maxDepth, err = strconv.Atoi(arg)
+	case ":history":
+		for i, h := range r.history[:len(r.history)-1] {
+			fmt.Fprintf(r.out, "%3d  %s\n", i+1, h)
+		}
+	default:
+		err = fmt.Errorf("unknown command %s, try :help", cmd)
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+	}
+	return true
+}
+
+func runRepl(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	strategy := fs.String("strategy", "need", "application strategy: need, name or value")
+	maxSteps := fs.Int64("max-steps", 0, "step budget per evaluation (0 for unlimited)")
+	maxDepth := fs.Int("max-depth", 0, "recursion depth budget per evaluation (0 for unlimited)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r := newRepl(stdout)
+	var err error
+	r.strategy, err = icfp.ParseStrategy(*strategy)
+	if err != nil {
+		return err
+	}
+	r.maxSteps = *maxSteps
+	r.maxDepth = *maxDepth
+	for _, file := range fs.Args() {
+		if err := r.load(file); err != nil {
+			return err
+		}
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(nil, 1<<24)
+	for {
+		fmt.Fprint(r.out, "icfp> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		if !r.handle(scanner.Text()) {
+			return nil
+		}
+	}
+}
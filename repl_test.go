@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepl(t *testing.T) {
+	var out bytes.Buffer
+	r := newRepl(&out)
+	assert.True(t, r.handle(`:let inc = λx.(+ x 1)`))
+	assert.True(t, r.handle(`:encode (inc 2)`))
+	assert.Equal(t, "B$ L! B+ v! I\" I#\n", out.String())
+
+	out.Reset()
+	assert.True(t, r.handle(`S'%4}).$%8`))
+	assert.Contains(t, out.String(), "\"get index\"\nget index\n[need: 1 steps")
+
+	out.Reset()
+	assert.True(t, r.handle(`:strategy fast`))
+	assert.Equal(t, "error: unknown strategy \"fast\" (want need, name or value)\n", out.String())
+	assert.False(t, r.handle(`:quit`))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lukehoban/icfp2024/icfp"
+)
+
+const timeFormat = "20060102T150405Z"
+
+func snapshotName(course string) string {
+	if course == "" {
+		return "overall"
+	}
+	return course
+}
+
+func save(dir string, sb *icfp.Scoreboard) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	byts, err := json.MarshalIndent(sb, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, snapshotName(sb.Course)+"-"+sb.Fetched.Format(timeFormat)+".json")
+	return path, os.WriteFile(path, append(byts, '\n'), 0644)
+}
+
+// snapshots returns the snapshot files for course, oldest first.
+func snapshots(dir, course string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, snapshotName(course)+"-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func load(path string) (*icfp.Scoreboard, error) {
+	byts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sb icfp.Scoreboard
+	if err := json.Unmarshal(byts, &sb); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sb, nil
+}
+
+func printDiff(w io.Writer, old, new *icfp.Scoreboard, team string) {
+	d := icfp.DiffScoreboards(old, new, team)
+	fmt.Fprintf(w, "%s: %s -> %s\n", snapshotName(new.Course), old.Fetched.Format(timeFormat), new.Fetched.Format(timeFormat))
+	if team != "" {
+		switch {
+		case d.OldRank == 0 && d.NewRank == 0:
+			fmt.Fprintf(w, "  %s is not on the board\n", team)
+		case d.NewRank == 0:
+			fmt.Fprintf(w, "  %s left the board from rank %d\n", team, d.OldRank)
+		case d.OldRank == 0:
+			fmt.Fprintf(w, "  %s joined the board at rank %d\n", team, d.NewRank)
+		case d.OldRank == d.NewRank:
+			fmt.Fprintf(w, "  %s stayed at rank %d\n", team, d.NewRank)
+		default:
+			fmt.Fprintf(w, "  %s moved from rank %d to %d\n", team, d.OldRank, d.NewRank)
+		}
+	}
+	if len(d.Joined) > 0 {
+		fmt.Fprintf(w, "  joined: %s\n", strings.Join(d.Joined, ", "))
+	}
+	if len(d.Left) > 0 {
+		fmt.Fprintf(w, "  left: %s\n", strings.Join(d.Left, ", "))
+	}
+	if len(d.Changes) == 0 {
+		fmt.Fprintln(w, "  no score changes")
+	}
+	for _, c := range d.Changes {
+		marker := " "
+		if c.Team == team {
+			marker = "*"
+		}
+		old, new := c.Old, c.New
+		if old == "" {
+			old = "-"
+		}
+		if new == "" {
+			new = "-"
+		}
+		fmt.Fprintf(w, " %s %s: %s %s -> %s\n", marker, c.Column, c.Team, old, new)
+	}
+}
+
+func fetch(dir string, courses []string) error {
+	c, err := icfp.DefaultClient()
+	if err != nil {
+		return err
+	}
+	for _, course := range courses {
+		sb, err := c.Scoreboard(context.Background(), course)
+		if err != nil {
+			return err
+		}
+		prev, err := snapshots(dir, course)
+		if err != nil {
+			return err
+		}
+		path, err := save(dir, sb)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved %s (%d teams)\n", path, len(sb.Rows))
+		if len(prev) > 0 {
+			old, err := load(prev[len(prev)-1])
+			if err != nil {
+				return err
+			}
+			printDiff(os.Stdout, old, sb, *team)
+		}
+	}
+	return nil
+}
+
+// diff compares the two given snapshots, or the latest two for each course.
+func diff(dir string, courses, args []string) error {
+	if len(args) == 2 {
+		old, err := load(args[0])
+		if err != nil {
+			return err
+		}
+		new, err := load(args[1])
+		if err != nil {
+			return err
+		}
+		printDiff(os.Stdout, old, new, *team)
+		return nil
+	}
+	for _, course := range courses {
+		paths, err := snapshots(dir, course)
+		if err != nil {
+			return err
+		}
+		if len(paths) < 2 {
+			fmt.Printf("%s: fewer than two snapshots in %s\n", snapshotName(course), dir)
+			continue
+		}
+		old, err := load(paths[len(paths)-2])
+		if err != nil {
+			return err
+		}
+		new, err := load(paths[len(paths)-1])
+		if err != nil {
+			return err
+		}
+		printDiff(os.Stdout, old, new, *team)
+	}
+	return nil
+}
+
+var team = flag.String("team", os.Getenv("ICFP_TEAM"), "our team name, to highlight on the boards")
+
+func main() {
+	dir := flag.String("dir", "scoreboard/snapshots", "directory for snapshots")
+	courseList := flag.String("courses", ",lambdaman,spaceship,3d,efficiency", "comma-separated courses; empty for the overall board")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: scoreboard [flags] fetch | diff [old.json new.json]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	courses := strings.Split(*courseList, ",")
+	var err error
+	switch flag.Arg(0) {
+	case "fetch":
+		err = fetch(*dir, courses)
+	case "diff":
+		err = diff(*dir, courses, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
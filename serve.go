@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/lukehoban/icfp2024/icfp/fakeserver"
+)
+
+func runServe(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8000", "address to listen on")
+	token := fs.String("token", "local", "bearer token clients must send")
+	fixtures := fs.String("fixtures", "icfp/fakeserver/testdata", "directory of <page>.txt files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Serving %s on http://%s\n", *fixtures, *addr)
+	return http.ListenAndServe(*addr, fakeserver.New(*token, *fixtures))
+}
@@ -1,58 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/lukehoban/icfp2024/icfp"
 )
 
-func do() error {
-	var wg sync.WaitGroup
+// walker solves spaceship problems by flying to each square in turn.
+type walker struct{}
 
-	for i := 1; i <= 25; i++ {
-		time.Sleep(100 * time.Second)
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
+func (walker) Name() string   { return "spaceship/walk" }
+func (walker) Course() string { return "spaceship" }
 
-			// if i < 16 {
-			// 	return
-			// }
-			in, err := icfp.CommunicateString(fmt.Sprintf("get spaceship%d", i))
-			if err != nil {
-				fmt.Printf("Failed to get spaceship%d: %v\n", i, err)
-				return
-			}
-			// fmt.Printf("Got back %s\n", string(in))
-			points, err := parse(string(in))
-			if err != nil {
-				fmt.Printf("Invalid response for spaceship%d: %v\n", i, err)
-				return
-			}
-			actions := Walk(points)
-			s := ""
-			for _, a := range actions {
-				s += strconv.Itoa(a)
-			}
-			fmt.Printf("Spaceship%d: %s\n", i, s)
-
-			answer, err := icfp.CommunicateString(fmt.Sprintf("solve spaceship%d %s", i, s))
-			if err != nil {
-				fmt.Printf("Failed to get spaceship%d: %v\n", i, err)
-				return
-			}
-			fmt.Printf("Response: %s\n", answer)
-		}(i)
+func (walker) Solve(ctx context.Context, input string) (string, int, error) {
+	points, err := parse(input)
+	if err != nil {
+		return "", 0, err
 	}
-
-	wg.Wait()
-
-	return nil
+	s := ""
+	for _, a := range Walk(points) {
+		s += strconv.Itoa(a)
+	}
+	// Spaceship is scored by the number of moves.
+	return s, len(s), nil
 }
 
 type Point struct {
@@ -138,18 +111,6 @@ func parse(s string) ([]Point, error) {
 	return points, nil
 }
 
-func readFile(s string) ([]Point, error) {
-	byts, err := os.ReadFile(s)
-	if err != nil {
-		return nil, err
-	}
-	return parse(string(byts))
-
-}
-
 func main() {
-	err := do()
-	if err != nil {
-		panic(err)
-	}
+	icfp.SolverMain(walker{})
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/lukehoban/icfp2024/icfp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,7 +36,9 @@ func TestMoveRel(t *testing.T) {
 }
 
 func Test1(t *testing.T) {
-	points, err := readFile("1.txt")
+	input, _, err := icfp.LoadProblem("spaceship1")
+	assert.NoError(t, err)
+	points, err := parse(input)
 	assert.NoError(t, err)
 
 	actions := Walk(points)